@@ -0,0 +1,269 @@
+package web
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	typeTime            = reflect.TypeOf(time.Time{})
+	typeDuration        = reflect.TypeOf(time.Duration(0))
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BindTimeLayouts is the time layout to parse time value when binding, the integer value is parsed as unix milliseconds
+var BindTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+
+// bindName will return the field name by json tag, the field name is used if tag is not set, empty is returned if it is skipped
+func bindName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if len(name) < 1 {
+		name = field.Name
+	}
+	return name
+}
+
+// BindSources is the struct tag of binding source, the first found source is used
+var BindSources = []string{"path", "query", "form", "header", "cookie"}
+
+// BindError is the error of binding field
+type BindError struct {
+	Field  string //the field name
+	Source string //the binding source
+	Value  string //the source value
+	Err    error
+}
+
+func (b *BindError) Error() string {
+	return fmt.Sprintf("bind %v from %v by value(%v) fail with %v", b.Field, b.Source, b.Value, b.Err)
+}
+
+// Unwrap will return the wrapped error
+func (b *BindError) Unwrap() error {
+	return b.Err
+}
+
+// BindErrors is the errors of all failed fields
+type BindErrors []*BindError
+
+func (b BindErrors) Error() string {
+	msgs := []string{}
+	for _, err := range b {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// BindLookup is the func to find field value, the name is the source key and source is the source tag
+type BindLookup func(field reflect.StructField) (name, source string, vals []string, ok bool)
+
+// bindFields will set struct field values by lookup, the embedded struct is bind recursively,
+// BindErrors is returned when some field is failed
+func bindFields(target reflect.Value, lookup BindLookup) (err error) {
+	target = reflect.Indirect(target)
+	if target.Kind() != reflect.Struct {
+		err = fmt.Errorf("bind target %v is not struct", target.Type())
+		return
+	}
+	errs := bindStruct(target, lookup, nil)
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+func bindStruct(target reflect.Value, lookup BindLookup, errs BindErrors) BindErrors {
+	targetType := target.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		if len(field.PkgPath) > 0 && !field.Anonymous {
+			continue
+		}
+		value := target.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			errs = bindStruct(value, lookup, errs)
+			continue
+		}
+		name, source, vals, ok := lookup(field)
+		if !ok || len(vals) < 1 {
+			continue
+		}
+		if err := bindValue(value, vals); err != nil {
+			errs = append(errs, &BindError{Field: name, Source: source, Value: strings.Join(vals, ","), Err: err})
+		}
+	}
+	return errs
+}
+
+// bindValue will set string values to target value, it support pointer, slice, number, bool, string, time and encoding.TextUnmarshaler
+func bindValue(target reflect.Value, vals []string) (err error) {
+	if target.Kind() == reflect.Ptr {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		err = bindValue(target.Elem(), vals)
+		return
+	}
+	if target.Kind() == reflect.Slice && target.Type().Elem().Kind() != reflect.Uint8 {
+		if len(vals) == 1 && strings.Contains(vals[0], ",") {
+			vals = strings.Split(vals[0], ",")
+		}
+		slice := reflect.MakeSlice(target.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err = bindValue(slice.Index(i), []string{strings.TrimSpace(val)}); err != nil {
+				return
+			}
+		}
+		target.Set(slice)
+		return
+	}
+	val := vals[0]
+	switch {
+	case target.Type() == typeTime:
+		var t time.Time
+		t, err = parseBindTime(val)
+		if err == nil {
+			target.Set(reflect.ValueOf(t))
+		}
+		return
+	case target.Type() == typeDuration:
+		var d time.Duration
+		d, err = time.ParseDuration(val)
+		if err == nil {
+			target.SetInt(int64(d))
+		}
+		return
+	case target.CanAddr() && target.Addr().Type().Implements(typeTextUnmarshaler):
+		err = target.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+		return
+	}
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(val)
+	case reflect.Slice:
+		target.SetBytes([]byte(val))
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(val)
+		if err == nil {
+			target.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(val, 10, target.Type().Bits())
+		if err == nil {
+			target.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(val, 10, target.Type().Bits())
+		if err == nil {
+			target.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		var n float64
+		n, err = strconv.ParseFloat(val, target.Type().Bits())
+		if err == nil {
+			target.SetFloat(n)
+		}
+	default:
+		err = fmt.Errorf("not supported type %v", target.Type())
+	}
+	return
+}
+
+func parseBindTime(val string) (t time.Time, err error) {
+	if ms, xerr := strconv.ParseInt(val, 10, 64); xerr == nil {
+		t = time.UnixMilli(ms)
+		return
+	}
+	for _, layout := range BindTimeLayouts {
+		if t, err = time.ParseInLocation(layout, val, time.Local); err == nil {
+			return
+		}
+	}
+	err = fmt.Errorf("invalid time value %v", val)
+	return
+}
+
+// BindRequest will bind request to target struct, the json body is decoded first if it is json request,
+// then the field is bound by tags like query:"page", header:"X-Tenant", cookie:"sid", path:"id", form:"name",
+// the field without source tag is bound from path params and form/query by json name
+func (s *Session) BindRequest(target interface{}) (err error) {
+	mediaType, _, _ := mime.ParseMediaType(s.R.Header.Get("Content-Type"))
+	if s.R.Body != nil && s.R.ContentLength != 0 && strings.HasSuffix(mediaType, "json") {
+		if err = json.NewDecoder(s.R.Body).Decode(target); err != nil {
+			err = ErrBadRequest.Wrap(err)
+			return
+		}
+	}
+	if mediaType == "multipart/form-data" && s.R.MultipartForm == nil {
+		s.R.ParseMultipartForm(32 << 20)
+	} else if s.R.Form == nil {
+		s.R.ParseForm()
+	}
+	params := s.PathParams()
+	query := s.R.URL.Query()
+	err = bindFields(reflect.ValueOf(target), func(field reflect.StructField) (name, source string, vals []string, ok bool) {
+		tagged := false
+		for _, source = range BindSources {
+			name = field.Tag.Get(source)
+			if len(name) < 1 || name == "-" {
+				continue
+			}
+			tagged = true
+			switch source {
+			case "path":
+				var val string
+				if val, ok = params[name]; ok {
+					vals = []string{val}
+				}
+			case "query":
+				vals, ok = query[name]
+			case "form":
+				vals, ok = s.R.PostForm[name]
+			case "header":
+				vals = s.R.Header.Values(name)
+				ok = len(vals) > 0
+			case "cookie":
+				if cookie, xerr := s.R.Cookie(name); xerr == nil {
+					vals, ok = []string{cookie.Value}, true
+				}
+			}
+			if ok {
+				return
+			}
+		}
+		if tagged {
+			return
+		}
+		if name = bindName(field); len(name) < 1 {
+			return
+		}
+		if val, having := params[name]; having {
+			return name, "path", []string{val}, true
+		}
+		vals, ok = s.R.Form[name]
+		source = "form"
+		return
+	})
+	return
+}
+
+// Bind will bind request to target struct by BindRequest, then valid it by ValidStruct with zero value included,
+// the valid field must have json name
+func (s *Session) Bind(target interface{}) (err error) {
+	if err = s.BindRequest(target); err == nil {
+		err = s.localizeValid(ValidStruct(target, "#all", ""))
+	}
+	return
+}
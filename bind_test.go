@@ -0,0 +1,97 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+type bindSearchReq struct {
+	ID      int64      `path:"id"`
+	Page    int        `json:"page" query:"page" valid:"page,O|I,R:1~"`
+	Tags    []string   `query:"tag"`
+	Since   *time.Time `query:"since"`
+	Tenant  string     `json:"tenant" header:"X-Tenant" valid:"tenant,R|S,L:1~"`
+	SID     string     `cookie:"sid"`
+	Name    string     `form:"name" query:"name"`
+	Keyword string     `json:"keyword"`
+	Other   string     `json:"other"`
+}
+
+func TestBind(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.HandleFunc(`^/search/(?P<id>[0-9]+)$`, func(s *Session) Result {
+		req := &bindSearchReq{}
+		if err := s.Bind(req); err != nil {
+			return s.SendErrorMapped(err)
+		}
+		return s.SendJSON(req)
+	})
+	ts := httptest.NewServer(mux)
+	//all source
+	res, _, err := xhttp.GetHeaderMap(xmap.M{"X-Tenant": "t1", "Cookie": "sid=s1"}, "%v/search/10?page=2&tag=a&tag=b&since=2020-01-02&name=q&keyword=k", ts.URL)
+	if err != nil || res.Int64("ID") != 10 || res.Int("page") != 2 || len(res.ArrayStrDef(nil, "Tags")) != 2 || res.Str("tenant") != "t1" ||
+		res.Str("SID") != "s1" || res.Str("Name") != "q" || res.Str("keyword") != "k" || !strings.HasPrefix(res.Str("Since"), "2020-01-02") {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//form and json
+	res, _, err = xhttp.PostHeaderMap(xmap.M{"X-Tenant": "t1", "Content-Type": "application/x-www-form-urlencoded"}, strings.NewReader("name=f&other=o"), "%v/search/11", ts.URL)
+	if err != nil || res.Str("Name") != "f" || res.Str("other") != "o" {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	res, _, err = xhttp.PostHeaderMap(xmap.M{"X-Tenant": "t1", "Content-Type": "application/json"}, strings.NewReader(`{"keyword":"j"}`), "%v/search/12?keyword=q", ts.URL)
+	if err != nil || res.Str("keyword") != "q" {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("name", "m")
+	writer.Close()
+	res, _, err = xhttp.PostHeaderMap(xmap.M{"X-Tenant": "t1", "Content-Type": writer.FormDataContentType()}, body, "%v/search/13", ts.URL)
+	if err != nil || res.Str("Name") != "m" {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//bind error
+	text, resp, _ := xhttp.GetHeaderText(xmap.M{"X-Tenant": "t1"}, "%v/search/14?page=x&since=x", ts.URL)
+	problem, _ := xmap.MapVal(text)
+	if resp.StatusCode != http.StatusBadRequest || len(problem.ArrayMapDef(nil, "errors")) != 2 || problem.StrDef("", "errors/0/field") != "page" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//valid error
+	text, resp, _ = xhttp.GetHeaderText(nil, "%v/search/15", ts.URL)
+	problem, _ = xmap.MapVal(text)
+	if resp.StatusCode != http.StatusBadRequest || problem.StrDef("", "errors/0/field") != "tenant" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//json error
+	_, resp, _ = xhttp.PostHeaderText(xmap.M{"Content-Type": "application/json"}, strings.NewReader(`{`), "%v/search/16", ts.URL)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	//error
+	session := &Session{R: httptest.NewRequest("GET", "/", nil)}
+	if err = session.Bind(1); err == nil {
+		t.Error(err)
+		return
+	}
+	bindErr := BindErrors{{Field: "a", Source: "query", Value: "x", Err: errors.New("error")}}
+	if !strings.Contains(bindErr.Error(), "bind a from query") || !errors.Is(bindErr[0], bindErr[0].Err) || !IsValidationError(bindErr) {
+		t.Error("error")
+		return
+	}
+}
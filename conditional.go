@@ -0,0 +1,129 @@
+package web
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SendOption is the option for Send* helpers to support cache policy and conditional request
+type SendOption struct {
+	ETag         string    //the caller-provided etag, it will be quoted if it is not
+	HashETag     bool      //generate strong etag by data hash when ETag is empty
+	LastModified time.Time //the last modified time of data
+	CacheControl string    //the Cache-Control header, Expires: 0 is sent when it is empty
+}
+
+// NewETagOption will return send option by caller-provided etag
+func NewETagOption(etag string) *SendOption {
+	return &SendOption{ETag: etag}
+}
+
+// NewHashETagOption will return send option which generate etag by data hash
+func NewHashETagOption() *SendOption {
+	return &SendOption{HashETag: true}
+}
+
+// HashETag will return strong etag by data hash
+func HashETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+func quoteETag(etag string) string {
+	if len(etag) < 1 || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// etagMatch will check if etag is in list value of If-Match/If-None-Match, weak is for weak comparison,
+// the * is matched to any current representation even if etag is empty
+func etagMatch(list, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if len(etag) < 1 {
+		return false
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, one := range strings.Split(list, ",") {
+		one = strings.TrimSpace(one)
+		if weak {
+			if strings.TrimPrefix(one, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if one == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConditional will check request preconditions by etag and modtime, return 0 for continue sending body,
+// or http.StatusNotModified/http.StatusPreconditionFailed for request is done
+func checkConditional(r *http.Request, etag string, modtime time.Time) (status int) {
+	header := r.Header
+	modtime = modtime.Truncate(time.Second)
+	if match := header.Get("If-Match"); len(match) > 0 {
+		if !etagMatch(match, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since := header.Get("If-Unmodified-Since"); len(since) > 0 && !modtime.IsZero() {
+		t, err := http.ParseTime(since)
+		if err == nil && modtime.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	isGet := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if match := header.Get("If-None-Match"); len(match) > 0 {
+		if etagMatch(match, etag, true) {
+			if isGet {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since := header.Get("If-Modified-Since"); len(since) > 0 && isGet && !modtime.IsZero() {
+		t, err := http.ParseTime(since)
+		if err == nil && !modtime.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// writeConditional will set cache headers and check conditional request, return true if request is done
+func writeConditional(w http.ResponseWriter, r *http.Request, data []byte, option *SendOption) (done bool) {
+	header := w.Header()
+	if option == nil || len(option.CacheControl) < 1 {
+		header.Set("Expires", "0")
+	} else {
+		header.Set("Cache-Control", option.CacheControl)
+	}
+	if option == nil {
+		return
+	}
+	etag := quoteETag(option.ETag)
+	if len(etag) < 1 && option.HashETag {
+		etag = HashETag(data)
+	}
+	if len(etag) > 0 {
+		header.Set("ETag", etag)
+	}
+	if !option.LastModified.IsZero() {
+		header.Set("Last-Modified", option.LastModified.UTC().Format(http.TimeFormat))
+	}
+	status := checkConditional(r, etag, option.LastModified)
+	if status == 0 {
+		return
+	}
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	w.WriteHeader(status)
+	done = true
+	return
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestSendConditional(t *testing.T) {
+	mux := NewSessionMux("")
+	modtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	mux.HandleFunc("/hash", func(s *Session) Result {
+		return s.SendString("abc", ContentTypePlainText, NewHashETagOption())
+	})
+	mux.HandleFunc("/etag", func(s *Session) Result {
+		return s.SendJSON(xmap.M{"a": 1}, &SendOption{ETag: "v1", CacheControl: "private, max-age=60"})
+	})
+	mux.HandleFunc("/modified", func(s *Session) Result {
+		return s.SendBytes([]byte("abc"), ContentTypePlainText, &SendOption{LastModified: modtime})
+	})
+	ts := httptest.NewServer(mux)
+	//hash etag
+	text, res, err := xhttp.GetHeaderText(nil, "%v/hash", ts.URL)
+	if err != nil || text != "abc" || res.Header.Get("ETag") != HashETag([]byte("abc")) || res.Header.Get("Expires") != "0" {
+		t.Errorf("err:%v,text:%v,res:%v", err, text, res.Header)
+		return
+	}
+	etag := res.Header.Get("ETag")
+	_, res, err = xhttp.GetHeaderText(xmap.M{"If-None-Match": etag}, "%v/hash", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotModified {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.GetHeaderText(xmap.M{"If-None-Match": `"xx", W/` + etag}, "%v/hash", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotModified {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//caller etag
+	_, res, err = xhttp.GetHeaderText(nil, "%v/etag", ts.URL)
+	if err != nil || res.Header.Get("ETag") != `"v1"` || res.Header.Get("Cache-Control") != "private, max-age=60" || len(res.Header.Get("Expires")) > 0 {
+		t.Errorf("err:%v,res:%v", err, res.Header)
+		return
+	}
+	_, res, err = xhttp.MethodText("PUT", xmap.M{"If-Match": `"v0"`}, nil, "%v/etag", ts.URL)
+	if err != nil || res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.MethodText("PUT", xmap.M{"If-Match": `"v1"`}, nil, "%v/etag", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.MethodText("PUT", xmap.M{"If-None-Match": `*`}, nil, "%v/etag", ts.URL)
+	if err != nil || res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//last modified
+	_, res, err = xhttp.GetHeaderText(nil, "%v/modified", ts.URL)
+	if err != nil || res.Header.Get("Last-Modified") != modtime.Format(http.TimeFormat) {
+		t.Errorf("err:%v,res:%v", err, res.Header)
+		return
+	}
+	_, res, err = xhttp.GetHeaderText(xmap.M{"If-Modified-Since": modtime.Format(http.TimeFormat)}, "%v/modified", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotModified {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.GetHeaderText(xmap.M{"If-Modified-Since": modtime.Add(-time.Hour).Format(http.TimeFormat)}, "%v/modified", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.MethodText("PUT", xmap.M{"If-Unmodified-Since": modtime.Add(-time.Hour).Format(http.TimeFormat)}, nil, "%v/modified", ts.URL)
+	if err != nil || res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.MethodText("PUT", xmap.M{"If-Match": `*`}, nil, "%v/modified", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//quote
+	if quoteETag(`W/"x"`) != `W/"x"` || quoteETag("x") != `"x"` || etagMatch("xx", "", true) || etagMatch(`W/"x"`, `W/"x"`, false) {
+		t.Error("error")
+		return
+	}
+}
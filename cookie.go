@@ -0,0 +1,192 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CookieHostPrefix is the cookie name prefix which requires Secure, Path=/ and no Domain
+const CookieHostPrefix = "__Host-"
+
+// ErrCookieKeyNotSet is the error of cookie sign key is not set
+var ErrCookieKeyNotSet = errors.New("cookie sign key is not set")
+
+// CookieOption is the attribute option to write cookie
+type CookieOption struct {
+	Domain      string
+	Path        string
+	MaxAge      int       //the max age in seconds, zero is session cookie
+	Expires     time.Time //the expires time, it is skipped when zero
+	Secure      bool
+	HttpOnly    bool
+	SameSite    http.SameSite
+	Partitioned bool     //append Partitioned attribute for CHIPS, Secure is forced
+	HostPrefix  bool     //prefix cookie name with __Host-, Secure and Path=/ is forced and Domain is cleared
+	Keys        [][]byte //the keys to sign cookie, the first key is used to sign, all keys is used to verify for rotating
+}
+
+// WithDefault will return the copy of option, the domain and path is used when the option Domain and Path is not set
+func (c CookieOption) WithDefault(domain, path string) (option *CookieOption) {
+	option = &c
+	if len(option.Domain) < 1 {
+		option.Domain = domain
+	}
+	if len(option.Path) < 1 {
+		option.Path = path
+	}
+	return
+}
+
+// Name will return the cookie name with prefix
+func (c *CookieOption) Name(name string) string {
+	if c.HostPrefix && !strings.HasPrefix(name, CookieHostPrefix) {
+		name = CookieHostPrefix + name
+	}
+	return name
+}
+
+// NewCookie will create cookie by option
+func (c *CookieOption) NewCookie(name, value string) (cookie *http.Cookie) {
+	cookie = &http.Cookie{
+		Name:     c.Name(name),
+		Value:    value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		MaxAge:   c.MaxAge,
+		Expires:  c.Expires,
+		Secure:   c.Secure || c.Partitioned,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+	if c.HostPrefix {
+		cookie.Secure = true
+		cookie.Path = "/"
+		cookie.Domain = ""
+	}
+	return
+}
+
+// SetCookie will create cookie by option and add Set-Cookie header to response
+func (c *CookieOption) SetCookie(w http.ResponseWriter, name, value string) {
+	c.WriteCookie(w, c.NewCookie(name, value))
+}
+
+// WriteCookie will add Set-Cookie header to response, the Partitioned attribute is appended when it is enabled
+func (c *CookieOption) WriteCookie(w http.ResponseWriter, cookie *http.Cookie) {
+	v := cookie.String()
+	if len(v) < 1 {
+		return
+	}
+	if c.Partitioned {
+		v += "; Partitioned"
+	}
+	w.Header().Add("Set-Cookie", v)
+}
+
+// Sign will sign cookie value by the first key, it returns value like base64(value).base64(hmac),
+// ErrCookieKeyNotSet is returned when Keys is empty
+func (c *CookieOption) Sign(name, value string) (signed string, err error) {
+	if len(c.Keys) < 1 {
+		err = ErrCookieKeyNotSet
+		return
+	}
+	value = base64.RawURLEncoding.EncodeToString([]byte(value))
+	signed = value + "." + base64.RawURLEncoding.EncodeToString(signCookie(c.Keys[0], c.Name(name), value))
+	return
+}
+
+// Verify will verify signed cookie value by all keys, the origin value is returned when verified
+func (c *CookieOption) Verify(name, signed string) (value string, ok bool) {
+	data, ok := verifyCookie(c.Keys, c.Name(name), signed)
+	if !ok {
+		return
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		ok = false
+		return
+	}
+	value = string(raw)
+	return
+}
+
+func signCookie(key []byte, name, value string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name + "|" + value))
+	return mac.Sum(nil)
+}
+
+// verifyCookie will verify value like data.base64(hmac) by keys and return the data
+func verifyCookie(keys [][]byte, name, value string) (data string, ok bool) {
+	idx := strings.LastIndex(value, ".")
+	if idx < 0 {
+		return
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(value[idx+1:])
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		if hmac.Equal(sum, signCookie(key, name, value[:idx])) {
+			data, ok = value[:idx], true
+			return
+		}
+	}
+	return
+}
+
+// MuxCookieOption will return the mux cookie option with the mux Domain and Path as default
+func (s *Session) MuxCookieOption() (option *CookieOption) {
+	if s.Mux == nil {
+		return &CookieOption{Path: "/"}
+	}
+	return s.Mux.Cookie.WithDefault(s.Mux.Domain, s.Mux.Path)
+}
+
+// SetCookie will set cookie by key/value and mux cookie option
+func (s *Session) SetCookie(key string, val string) {
+	s.SetCookieOption(key, val, s.MuxCookieOption())
+}
+
+// SetCookieOption will set cookie by key/value and option
+func (s *Session) SetCookieOption(key string, val string, option *CookieOption) {
+	option.SetCookie(s.W, key, val)
+}
+
+// Cookie will return cookie value by key, the key is prefixed when mux cookie option HostPrefix is enabled
+func (s *Session) Cookie(key string) (val string) {
+	c, err := s.R.Cookie(s.MuxCookieOption().Name(key))
+	if err == nil && c != nil {
+		val = c.Value
+	}
+	return
+}
+
+// DeleteCookie will delete cookie by key and mux cookie option
+func (s *Session) DeleteCookie(key string) {
+	option := s.MuxCookieOption()
+	option.MaxAge = -1
+	option.Expires = time.Unix(1, 0)
+	option.SetCookie(s.W, key, "")
+}
+
+// SetSignedCookie will set cookie which is signed by mux cookie option keys, ErrCookieKeyNotSet is returned when keys is not set
+func (s *Session) SetSignedCookie(key string, val string) (err error) {
+	option := s.MuxCookieOption()
+	signed, err := option.Sign(key, val)
+	if err == nil {
+		option.SetCookie(s.W, key, signed)
+	}
+	return
+}
+
+// SignedCookie will return the verified value of signed cookie, empty is returned when it is not found or not verified
+func (s *Session) SignedCookie(key string) (val string) {
+	val, _ = s.MuxCookieOption().Verify(key, s.Cookie(key))
+	return
+}
@@ -0,0 +1,490 @@
+package web
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codingeasygo/util/uuid"
+	"github.com/codingeasygo/util/xmap"
+)
+
+// ErrCookieTooLarge is the error of encoded session cookie is larger than MaxSize
+var ErrCookieTooLarge = errors.New("session cookie too large")
+
+// ErrCookieHeaderSent is the error of session is changed after response header is sent
+var ErrCookieHeaderSent = errors.New("session cookie can't be written after response header is sent")
+
+// ErrCookieInvalid is the error of session cookie is not verified or decrypted by any key
+var ErrCookieInvalid = errors.New("session cookie invalid")
+
+// ErrCookieKeyInvalid is the error of session cookie key is empty or not AES key length when encrypt
+var ErrCookieKeyInvalid = errors.New("session cookie key invalid")
+
+// responseWrapper is the interface for session to wrap response writer, it is used to write session before response header is sent
+type responseWrapper interface {
+	wrapResponse(w http.ResponseWriter) http.ResponseWriter
+}
+
+// headerSender is the interface for session to write session to response header, it is used when response is hijacked
+type headerSender interface {
+	sendHeader() error
+}
+
+type cookiePayload struct {
+	ID     string                 `json:"i"`
+	Latest int64                  `json:"t"`
+	Values map[string]interface{} `json:"v"`
+}
+
+// CookieSession is session implement which stores values in cookie
+type CookieSession struct {
+	xmap.Valuable
+	id        string
+	latest    time.Time
+	origin    []byte //the json values when loaded or written
+	created   bool
+	sent      bool
+	destroyed bool
+	dirty     int32 //the values is changed by SetValue, Delete or Clear
+	builder   *CookieSessionBuilder
+	w         http.ResponseWriter
+	locker    sync.Mutex
+}
+
+// ID will return the session id, it is kept in cookie
+func (c *CookieSession) ID() string {
+	return c.id
+}
+
+// Latest will return the time of cookie written
+func (c *CookieSession) Latest() time.Time {
+	return c.latest
+}
+
+// SetValue will set session value and mark session dirty
+func (c *CookieSession) SetValue(path string, val interface{}) (err error) {
+	if err = c.Valuable.SetValue(path, val); err == nil {
+		atomic.StoreInt32(&c.dirty, 1)
+	}
+	return
+}
+
+// Delete will delete session value and mark session dirty
+func (c *CookieSession) Delete(path string) (err error) {
+	if err = c.Valuable.Delete(path); err == nil {
+		atomic.StoreInt32(&c.dirty, 1)
+	}
+	return
+}
+
+// Clear will clear session value and mark session dirty
+func (c *CookieSession) Clear() (err error) {
+	if err = c.Valuable.Clear(); err == nil {
+		atomic.StoreInt32(&c.dirty, 1)
+	}
+	return
+}
+
+// Flush will write cookie when values is changed by SetValue, Delete, Clear or half of timeout is passed,
+// ErrCookieHeaderSent is returned when values is changed after response header is sent
+func (c *CookieSession) Flush() (err error) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	err = c.writeCookie()
+	return
+}
+
+// IsDirty is implement for DirtySession, it is true when the cookie need to be written
+func (c *CookieSession) IsDirty() bool {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.w != nil && !c.destroyed && (c.refresh() || atomic.LoadInt32(&c.dirty) == 1)
+}
+
+// refresh will return true when cookie is new created or half of timeout is passed
+func (c *CookieSession) refresh() bool {
+	timeout := c.builder.Timeout
+	return c.created || (timeout > 0 && time.Since(c.latest) >= timeout/2)
+}
+
+func (c *CookieSession) writeCookie() (err error) {
+	if c.w == nil || c.destroyed {
+		return
+	}
+	refresh := c.refresh()
+	if !refresh && atomic.LoadInt32(&c.dirty) == 0 {
+		return
+	}
+	data, err := json.Marshal(copyValues(c.Valuable))
+	if err != nil {
+		return
+	}
+	if !refresh && bytes.Equal(data, c.origin) {
+		atomic.StoreInt32(&c.dirty, 0)
+		return
+	}
+	if c.sent {
+		err = ErrCookieHeaderSent
+		return
+	}
+	now := time.Now()
+	value, err := c.builder.Encode(&cookiePayload{ID: c.id, Latest: now.Unix(), Values: copyValues(c.Valuable)})
+	if err != nil {
+		return
+	}
+	option := c.builder.Cookie.WithDefault(c.builder.Domain, c.builder.Path)
+	option.SetCookie(c.w, c.builder.CookieKey, value)
+	c.origin, c.latest, c.created = data, now, false
+	atomic.StoreInt32(&c.dirty, 0)
+	return
+}
+
+func (c *CookieSession) sendHeader() (err error) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if c.sent {
+		return
+	}
+	err = c.writeCookie()
+	c.sent = true
+	return
+}
+
+func (c *CookieSession) wrapResponse(w http.ResponseWriter) http.ResponseWriter {
+	return &cookieSessionWriter{ResponseWriter: w, session: c}
+}
+
+// cookieSessionWriter will write session cookie before response header is sent
+type cookieSessionWriter struct {
+	http.ResponseWriter
+	session *CookieSession
+	header  bool
+	err     error
+}
+
+func (c *cookieSessionWriter) WriteHeader(code int) {
+	if c.header {
+		return
+	}
+	c.header = true
+	if c.err = c.session.sendHeader(); c.err != nil {
+		ErrorLog("CookieSession write session %v fail with %v", c.session.id, c.err)
+		code = http.StatusInternalServerError
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *cookieSessionWriter) Write(p []byte) (n int, err error) {
+	c.WriteHeader(http.StatusOK)
+	if c.err != nil {
+		err = c.err
+		return
+	}
+	n, err = c.ResponseWriter.Write(p)
+	return
+}
+
+func (c *cookieSessionWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// CookieSessionBuilder is session builder which stores session values in cookie, it is signed by HMAC-SHA256 or encrypted by AES-GCM,
+// so the session is stateless and need not shared store.
+type CookieSessionBuilder struct {
+	Domain    string
+	Path      string
+	Timeout   time.Duration //the session timeout
+	CookieKey string        //cookie key
+	Cookie    CookieOption  //the session cookie option, see CookieOption.WithDefault
+	Keys      [][]byte      //the first key is used to sign or encrypt, all keys is used to verify or decrypt for rotating
+	Encrypt   bool          //encrypt by AES-GCM when it is true, the key length must be 16, 24 or 32
+	MaxSize   int           //the max size of serialized cookie with all attributes, default is 4096
+	Event     SessionEventHandler
+}
+
+// NewCookieSessionBuilder will return new CookieSessionBuilder which signs session by HMAC-SHA256,
+// ErrCookieKeyNotSet or ErrCookieKeyInvalid is returned when keys is empty or invalid
+func NewCookieSessionBuilder(domain string, path string, cookie string, timeout time.Duration, keys ...[]byte) (builder *CookieSessionBuilder, err error) {
+	builder = &CookieSessionBuilder{
+		Domain:    domain,
+		Path:      path,
+		Timeout:   timeout,
+		CookieKey: cookie,
+		Cookie:    CookieOption{HttpOnly: true, MaxAge: int(timeout / time.Second)},
+		Keys:      keys,
+		MaxSize:   4096,
+	}
+	if err = builder.CheckKeys(); err != nil {
+		builder = nil
+	}
+	return
+}
+
+// NewEncryptedCookieSessionBuilder will return new CookieSessionBuilder which encrypts session by AES-GCM, the key length must be 16, 24 or 32
+func NewEncryptedCookieSessionBuilder(domain string, path string, cookie string, timeout time.Duration, keys ...[]byte) (builder *CookieSessionBuilder, err error) {
+	builder, err = NewCookieSessionBuilder(domain, path, cookie, timeout, keys...)
+	if err == nil {
+		builder.Encrypt = true
+		if err = builder.CheckKeys(); err != nil {
+			builder = nil
+		}
+	}
+	return
+}
+
+// CheckKeys will check the Keys is valid for signing or encrypting, it should be called after Keys or Encrypt is changed
+func (c *CookieSessionBuilder) CheckKeys() (err error) {
+	if len(c.Keys) < 1 {
+		err = ErrCookieKeyNotSet
+		return
+	}
+	for _, key := range c.Keys {
+		if len(key) < 1 {
+			err = ErrCookieKeyInvalid
+			return
+		}
+		if c.Encrypt {
+			if _, xerr := aes.NewCipher(key); xerr != nil {
+				err = fmt.Errorf("%w: %v", ErrCookieKeyInvalid, xerr)
+				return
+			}
+		}
+	}
+	return
+}
+
+// Encode will sign or encrypt session payload to cookie value, ErrCookieTooLarge is returned when serialized cookie is larger than MaxSize
+func (c *CookieSessionBuilder) Encode(payload interface{}) (value string, err error) {
+	if len(c.Keys) < 1 {
+		err = ErrCookieKeyNotSet
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if c.Encrypt {
+		var aead cipher.AEAD
+		if aead, err = newCookieAEAD(c.Keys[0]); err != nil {
+			return
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+			return
+		}
+		value = base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, data, []byte(c.CookieKey)))
+	} else {
+		value = base64.RawURLEncoding.EncodeToString(data)
+		value += "." + base64.RawURLEncoding.EncodeToString(signCookie(c.Keys[0], c.CookieKey, value))
+	}
+	if size := c.cookieSize(value); c.MaxSize > 0 && size > c.MaxSize {
+		err = fmt.Errorf("%w: %v bytes over %v", ErrCookieTooLarge, size, c.MaxSize)
+	}
+	return
+}
+
+// cookieSize will return the size of serialized Set-Cookie value with all attributes
+func (c *CookieSessionBuilder) cookieSize(value string) (size int) {
+	option := c.Cookie.WithDefault(c.Domain, c.Path)
+	size = len(option.NewCookie(c.CookieKey, value).String())
+	if option.Partitioned {
+		size += len("; Partitioned")
+	}
+	return
+}
+
+// Decode will verify or decrypt cookie value by all keys and unmarshal to payload
+func (c *CookieSessionBuilder) Decode(value string, payload interface{}) (err error) {
+	var data []byte
+	if c.Encrypt {
+		data, err = c.decrypt(value)
+	} else {
+		data, err = c.verify(value)
+	}
+	if err == nil {
+		err = json.Unmarshal(data, payload)
+	}
+	return
+}
+
+func (c *CookieSessionBuilder) verify(value string) (data []byte, err error) {
+	signed, ok := verifyCookie(c.Keys, c.CookieKey, value)
+	if !ok {
+		err = ErrCookieInvalid
+		return
+	}
+	data, err = base64.RawURLEncoding.DecodeString(signed)
+	return
+}
+
+func (c *CookieSessionBuilder) decrypt(value string) (data []byte, err error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		err = ErrCookieInvalid
+		return
+	}
+	for _, key := range c.Keys {
+		aead, xerr := newCookieAEAD(key)
+		if xerr != nil || len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, text := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if data, xerr = aead.Open(nil, nonce, text, []byte(c.CookieKey)); xerr == nil {
+			return
+		}
+	}
+	err = ErrCookieInvalid
+	return
+}
+
+func newCookieAEAD(key []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err == nil {
+		aead, err = cipher.NewGCM(block)
+	}
+	return
+}
+
+// load will load session from request cookie, nil is returned when cookie is not found, invalid or timeout
+func (c *CookieSessionBuilder) load(r *http.Request) (session *CookieSession) {
+	cookie, err := r.Cookie(c.Cookie.WithDefault(c.Domain, c.Path).Name(c.CookieKey))
+	if err != nil {
+		return
+	}
+	payload := &cookiePayload{}
+	if err = c.Decode(cookie.Value, payload); err != nil || len(payload.ID) < 1 {
+		DebugLog("CookieSessionBuilder decode session cookie fail with %v", err)
+		return
+	}
+	if payload.Values == nil {
+		payload.Values = map[string]interface{}{}
+	}
+	session = &CookieSession{id: payload.ID, latest: time.Unix(payload.Latest, 0), builder: c}
+	session.origin, _ = json.Marshal(payload.Values)
+	session.Valuable = xmap.WrapSafe(payload.Values)
+	if c.Timeout > 0 && time.Since(session.latest) > c.Timeout {
+		if c.Event != nil {
+			c.Event.OnTimeout(session)
+		}
+		session = nil
+	}
+	return
+}
+
+// Find is not supported by cookie session, it always return nil
+func (c *CookieSessionBuilder) Find(id string) Sessionable {
+	return nil
+}
+
+// FindSession will load session from request cookie, the new session is created when w is not nil and cookie is not valid
+func (c *CookieSessionBuilder) FindSession(w http.ResponseWriter, r *http.Request) Sessionable {
+	session := c.load(r)
+	if w == nil {
+		if session == nil {
+			return nil
+		}
+		return session
+	}
+	if session == nil {
+		session = &CookieSession{id: uuid.New(), latest: time.Now(), created: true, builder: c}
+		session.Valuable = xmap.NewSafe()
+		if c.Event != nil {
+			c.Event.OnCreate(session)
+		}
+	}
+	session.w = w
+	return session
+}
+
+// SetEventHandler will set event handler
+func (c *CookieSessionBuilder) SetEventHandler(h SessionEventHandler) {
+	c.Event = h
+}
+
+// Regenerate is implement for SessionLifecycle, the session id is changed in place and cookie is written on Flush
+func (c *CookieSessionBuilder) Regenerate(w http.ResponseWriter, s Sessionable) (session Sessionable, err error) {
+	cs, ok := s.(*CookieSession)
+	if !ok {
+		err = ErrSessionUnsupported
+		return
+	}
+	cs.locker.Lock()
+	if cs.sent {
+		err = ErrCookieHeaderSent
+	} else if cs.destroyed {
+		err = ErrSessionNotFound
+	}
+	old := cs.id
+	if err == nil {
+		cs.id, cs.created = uuid.New(), true
+	}
+	cs.locker.Unlock()
+	if err != nil {
+		return
+	}
+	if c.Event != nil {
+		c.Event.OnRegenerate(old, cs)
+	}
+	session = cs
+	return
+}
+
+// Destroy is implement for SessionLifecycle, the session cookie is deleted
+func (c *CookieSessionBuilder) Destroy(w http.ResponseWriter, s Sessionable) (err error) {
+	cs, ok := s.(*CookieSession)
+	if !ok {
+		err = ErrSessionUnsupported
+		return
+	}
+	cs.locker.Lock()
+	if cs.sent {
+		err = ErrCookieHeaderSent
+	} else if cs.destroyed {
+		err = ErrSessionNotFound
+	}
+	if err == nil {
+		if cs.w != nil {
+			option := c.Cookie.WithDefault(c.Domain, c.Path)
+			option.MaxAge = -1
+			option.SetCookie(cs.w, c.CookieKey, "")
+		}
+		cs.destroyed = true
+	}
+	cs.locker.Unlock()
+	if err == nil && c.Event != nil {
+		c.Event.OnDestroy(cs)
+	}
+	return
+}
+
+// BindUser is implement for SessionLifecycle, the user is stored to session value by _user_
+func (c *CookieSessionBuilder) BindUser(s Sessionable, user string) (err error) {
+	if _, ok := s.(*CookieSession); !ok {
+		err = ErrSessionUnsupported
+		return
+	}
+	s.SetValue("_user_", user)
+	return
+}
+
+// FindByUser is not supported by cookie session, it always return nil
+func (c *CookieSessionBuilder) FindByUser(user string) (sessions []Sessionable) {
+	return
+}
+
+// DestroyUser is not supported by cookie session, it always return ErrSessionUnsupported
+func (c *CookieSessionBuilder) DestroyUser(user string) (count int, err error) {
+	err = ErrSessionUnsupported
+	return
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieSession(t *testing.T) {
+	keyA, keyB := []byte("0123456789abcdef0123456789abcdef"), []byte("fedcba9876543210fedcba9876543210")
+	for _, encrypt := range []bool{false, true} {
+		builder, _ := NewCookieSessionBuilder("", "/", "ctest", time.Minute, keyA)
+		if encrypt {
+			builder, _ = NewEncryptedCookieSessionBuilder("", "/", "ctest", time.Minute, keyA)
+		}
+		created := 0
+		builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+			if key == "CREATE" {
+				created++
+			}
+		}))
+		mux := NewBuilderSessionMux("", builder)
+		mux.HandleFunc("^/set$", func(s *Session) Result {
+			s.SetValue("name", s.R.URL.Query().Get("name"))
+			return s.Printf("%v", s.ID())
+		})
+		mux.HandleFunc("^/get$", func(s *Session) Result {
+			return s.Printf("%v:%v", s.ID(), s.Str("name"))
+		})
+		mux.HandleFunc("^/none$", func(s *Session) Result {
+			return Return
+		})
+		mux.HandleFunc("^/late$", func(s *Session) Result {
+			s.Printf("late")
+			s.SetValue("name", "late")
+			return Return
+		})
+		w := serveCookie(mux, "/set?name=abc", "")
+		sid, cookie := w.Body.String(), responseCookie(w, "ctest")
+		if len(sid) < 1 || cookie == nil || !cookie.HttpOnly || cookie.MaxAge != 60 || created != 1 {
+			t.Errorf("sid:%v,cookie:%v", sid, cookie)
+			return
+		}
+		if encrypt == strings.Contains(cookie.Value, ".") {
+			t.Errorf("cookie:%v", cookie)
+			return
+		}
+		//not changed
+		w = serveCookie(mux, "/get", "ctest="+cookie.Value)
+		text, setCookie := w.Body.String(), responseCookie(w, "ctest")
+		if text != sid+":abc" || setCookie != nil {
+			t.Errorf("text:%v,cookie:%v", text, setCookie)
+			return
+		}
+		//tampered
+		w = serveCookie(mux, "/get", "ctest="+cookie.Value[:len(cookie.Value)-2]+"xx")
+		text, setCookie = w.Body.String(), responseCookie(w, "ctest")
+		if text == sid+":abc" || setCookie == nil || created != 2 {
+			t.Errorf("text:%v,cookie:%v", text, setCookie)
+			return
+		}
+		//rotating
+		builder.Keys = [][]byte{keyB, keyA}
+		w = serveCookie(mux, "/set?name=xyz", "ctest="+cookie.Value)
+		text, setCookie = w.Body.String(), responseCookie(w, "ctest")
+		if text != sid || setCookie == nil {
+			t.Errorf("text:%v,cookie:%v", text, setCookie)
+			return
+		}
+		builder.Keys = [][]byte{keyB}
+		if text = serveCookie(mux, "/get", "ctest="+setCookie.Value).Body.String(); text != sid+":xyz" {
+			t.Errorf("text:%v", text)
+			return
+		}
+		if text = serveCookie(mux, "/get", "ctest="+cookie.Value).Body.String(); text == sid+":abc" {
+			t.Errorf("text:%v", text)
+			return
+		}
+		//no response
+		if setCookie = responseCookie(serveCookie(mux, "/none", ""), "ctest"); setCookie == nil {
+			t.Errorf("cookie:%v", setCookie)
+			return
+		}
+		//timeout
+		value, _ := builder.Encode(&cookiePayload{ID: "old", Latest: time.Now().Add(-time.Hour).Unix()})
+		if text = serveCookie(mux, "/get", "ctest="+value).Body.String(); strings.HasPrefix(text, "old:") {
+			t.Errorf("text:%v", text)
+			return
+		}
+		//too large
+		w = serveCookie(mux, "/set?name="+strings.Repeat("x", 5000), "")
+		if w.Code != http.StatusInternalServerError || responseCookie(w, "ctest") != nil || w.Body.Len() > 0 {
+			t.Errorf("text:%v,code:%v", w.Body.String(), w.Code)
+			return
+		}
+		//changed after header sent
+		req := httptest.NewRequest("GET", "/", nil)
+		session := builder.FindSession(nil, req)
+		if session != nil {
+			t.Errorf("session:%v", session)
+			return
+		}
+		w = httptest.NewRecorder()
+		session = builder.FindSession(w, req)
+		writer := session.(responseWrapper).wrapResponse(w)
+		writer.Write([]byte("abc"))
+		session.SetValue("a", 1)
+		if err := session.Flush(); err != ErrCookieHeaderSent {
+			t.Errorf("err:%v", err)
+			return
+		}
+	}
+	//error
+	if _, err := NewCookieSessionBuilder("", "/", "ctest", 0); err != ErrCookieKeyNotSet {
+		t.Error(err)
+		return
+	}
+	if _, err := NewCookieSessionBuilder("", "/", "ctest", 0, []byte("123"), nil); err != ErrCookieKeyInvalid {
+		t.Error(err)
+		return
+	}
+	if _, err := NewEncryptedCookieSessionBuilder("", "/", "ctest", 0, []byte("123")); !errors.Is(err, ErrCookieKeyInvalid) {
+		t.Error(err)
+		return
+	}
+	builder, _ := NewCookieSessionBuilder("", "/", "ctest", 0, []byte("123"))
+	builder.Keys = nil
+	if _, err := builder.Encode(nil); err == nil {
+		t.Error(err)
+		return
+	}
+	builder.Keys, builder.Encrypt = [][]byte{[]byte("123")}, true
+	if _, err := builder.Encode(nil); err == nil {
+		t.Error(err)
+		return
+	}
+	builder.MaxSize = 10
+	builder.Keys, builder.Encrypt = [][]byte{[]byte("123")}, false
+	if _, err := builder.Encode(map[string]interface{}{"a": 1}); !errors.Is(err, ErrCookieTooLarge) {
+		t.Error(err)
+		return
+	}
+	builder.MaxSize = 0
+	value, _ := builder.Encode(map[string]interface{}{"a": 1})
+	builder.MaxSize = len("ctest=") + len(value) + 10
+	builder.Domain, builder.Cookie.SameSite = "example.com", http.SameSiteLaxMode
+	if _, err := builder.Encode(map[string]interface{}{"a": 1}); !errors.Is(err, ErrCookieTooLarge) {
+		t.Error(err)
+		return
+	}
+	for _, value := range []string{"abc", "abc.!", "abc.YWJj", "!"} {
+		if err := builder.Decode(value, &cookiePayload{}); err != ErrCookieInvalid {
+			t.Errorf("%v->%v", value, err)
+			return
+		}
+	}
+	builder.Encrypt = true
+	if err := builder.Decode("YWJj", &cookiePayload{}); err != ErrCookieInvalid || builder.Find("x") != nil {
+		t.Error(err)
+		return
+	}
+}
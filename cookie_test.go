@@ -0,0 +1,127 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieOption(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.Domain = "example.com"
+	mux.HandleFunc("^/set$", func(s *Session) Result {
+		s.SetCookie("a", "123")
+		s.SetCookieOption("b", "456", &CookieOption{Path: "/b", Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode, MaxAge: 60, Partitioned: true})
+		s.DeleteCookie("c")
+		return s.Printf("%v", s.Cookie("a"))
+	})
+	mux.HandleFunc("^/signed/set$", func(s *Session) Result {
+		s.SetSignedCookie("s", s.R.URL.Query().Get("v"))
+		return Return
+	})
+	mux.HandleFunc("^/signed/get$", func(s *Session) Result {
+		return s.Printf("%v", s.SignedCookie("s"))
+	})
+	w := serveCookie(mux, "/set", "a=abc")
+	text, cookies := w.Body.String(), w.Header().Values("Set-Cookie")
+	if text != "abc" || len(cookies) != 3 ||
+		cookies[0] != "a=123; Path=/; Domain=example.com" ||
+		cookies[1] != "b=456; Path=/b; Max-Age=60; HttpOnly; Secure; SameSite=Strict; Partitioned" ||
+		!strings.HasPrefix(cookies[2], "c=; Path=/; Domain=example.com; Expires=") || !strings.HasSuffix(cookies[2], "; Max-Age=0") {
+		t.Errorf("text:%v,cookies:%v", text, strings.Join(cookies, "\n"))
+		return
+	}
+	//host prefix
+	mux.Cookie = CookieOption{HostPrefix: true, Path: "/x", SameSite: http.SameSiteLaxMode}
+	w = serveCookie(mux, "/set", "a=abc;__Host-a=xyz")
+	text, cookies = w.Body.String(), w.Header().Values("Set-Cookie")
+	if text != "xyz" || cookies[0] != "__Host-a=123; Path=/; Secure; SameSite=Lax" {
+		t.Errorf("text:%v,cookies:%v", text, strings.Join(cookies, "\n"))
+		return
+	}
+	//signed
+	mux.Cookie = CookieOption{Keys: [][]byte{[]byte("key1")}}
+	cookies = serveCookie(mux, "/signed/set?v=a%3Bb%20c", "").Header().Values("Set-Cookie")
+	signed := strings.SplitN(strings.SplitN(cookies[0], ";", 2)[0], "=", 2)[1]
+	if text = serveCookie(mux, "/signed/get", "s="+signed).Body.String(); text != "a;b c" {
+		t.Errorf("text:%v,cookies:%v", text, cookies)
+		return
+	}
+	if text = serveCookie(mux, "/signed/get", "s="+signed[:len(signed)-1]+"x").Body.String(); text != "" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text = serveCookie(mux, "/signed/get", "s="+signed+"x").Body.String(); text != "" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	mux.Cookie.Keys = [][]byte{[]byte("key2"), []byte("key1")}
+	if text = serveCookie(mux, "/signed/get", "s="+signed).Body.String(); text != "a;b c" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	mux.Cookie.Keys = [][]byte{[]byte("key2")}
+	if text = serveCookie(mux, "/signed/get", "s="+signed).Body.String(); text != "" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if _, ok := mux.Cookie.Verify("s", "!.!"); ok {
+		t.Error("error")
+		return
+	}
+	if signed, _ := mux.Cookie.Sign("s", ""); len(signed) < 1 {
+		t.Error("error")
+		return
+	} else if _, ok := mux.Cookie.Verify("s", "!."+signed[1:]); ok {
+		t.Error("error")
+		return
+	}
+	if _, err := (&CookieOption{}).Sign("s", ""); err != ErrCookieKeyNotSet {
+		t.Error(err)
+		return
+	}
+	//no mux
+	session := &Session{W: httptest.NewRecorder(), R: httptest.NewRequest("GET", "/", nil)}
+	session.SetCookie("a", "1")
+	if cookie := session.W.Header().Get("Set-Cookie"); cookie != "a=1; Path=/" {
+		t.Errorf("cookie:%v", cookie)
+		return
+	}
+	session.SetCookieOption("a b", "1", &CookieOption{})
+	if cookies := session.W.Header().Values("Set-Cookie"); len(cookies) != 1 {
+		t.Errorf("cookies:%v", cookies)
+		return
+	}
+}
+
+func TestMemSessionCookie(t *testing.T) {
+	builder := NewMemSessionBuilder("", "/", "mcookie", time.Minute)
+	builder.Cookie.Secure = true
+	builder.Cookie.HostPrefix = true
+	mux := NewBuilderSessionMux("", builder)
+	mux.HandleFunc("^/id$", func(s *Session) Result {
+		return s.Printf("%v", s.ID())
+	})
+	w := serveCookie(mux, "/id", "")
+	sid, cookie := w.Body.String(), responseCookie(w, "__Host-mcookie")
+	if cookie == nil || cookie.Name != "__Host-mcookie" || cookie.Value != sid || cookie.MaxAge != 60 || !cookie.Secure || !cookie.HttpOnly {
+		t.Errorf("sid:%v,cookie:%v", sid, cookie)
+		return
+	}
+	if w = serveCookie(mux, "/id", "__Host-mcookie="+sid); w.Body.String() != sid || responseCookie(w, "__Host-mcookie") != nil {
+		t.Errorf("text:%v,cookie:%v", w.Body.String(), w.Header())
+		return
+	}
+	if text := serveCookie(mux, "/id", "mcookie="+sid).Body.String(); text == sid {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//refresh
+	builder.Clock = ClockFunc(func() time.Time { return time.Now().Add(time.Minute) })
+	if w = serveCookie(mux, "/id", "__Host-mcookie="+sid); w.Body.String() != sid || responseCookie(w, "__Host-mcookie") == nil {
+		t.Errorf("text:%v,cookie:%v", w.Body.String(), w.Header())
+		return
+	}
+}
@@ -3,4 +3,5 @@ package web
 const (
 	ContentTypePlainText = "text/plain;charset=UTF-8"
 	ContentTypeJSON      = "application/json;charset=UTF-8"
+	ContentTypeNDJSON    = "application/x-ndjson"
 )
@@ -0,0 +1,230 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// HTTPError is the typed error which carry http status and application error code,
+// the message is public for client and the Err is the wrapped error which is only shown in debug mode
+type HTTPError struct {
+	Status  int
+	Code    int
+	Key     string //the local key of message, the registered code key is used when it is empty
+	Message string
+	Err     error
+}
+
+// NewHTTPError will return new typed error by http status, application code and message
+func NewHTTPError(status, code int, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+// NewLocalHTTPError will return new typed error by http status, application code, local key and default message
+func NewLocalHTTPError(status, code int, key, message string) *HTTPError {
+	return &HTTPError{Status: status, Code: code, Key: key, Message: message}
+}
+
+func (h *HTTPError) Error() string {
+	if h.Err != nil {
+		return fmt.Sprintf("%v: %v", h.Message, h.Err)
+	}
+	return h.Message
+}
+
+// Unwrap will return the wrapped error
+func (h *HTTPError) Unwrap() error {
+	return h.Err
+}
+
+// Is will check if target is same kind error, it is true when err is wrapped from target sentinel by Wrap
+func (h *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Err == nil && t.Status == h.Status && t.Code == h.Code && t.Message == h.Message
+}
+
+// Wrap will return new typed error which wrap err by current status, code and message
+func (h *HTTPError) Wrap(err error) *HTTPError {
+	return &HTTPError{Status: h.Status, Code: h.Code, Key: h.Key, Message: h.Message, Err: err}
+}
+
+// localKey will return the local key of error, the registered code key is returned when Key is empty
+func (h *HTTPError) localKey() string {
+	if len(h.Key) > 0 {
+		return h.Key
+	}
+	if define := ErrorCodes.Find(h.Code); define != nil {
+		return define.Key
+	}
+	return ""
+}
+
+var (
+	//ErrBadRequest is the error of bad request
+	ErrBadRequest = NewLocalHTTPError(http.StatusBadRequest, CodeArgInvalid, "error.bad_request", "bad request")
+	//ErrValidation is the error of request validation failed
+	ErrValidation = NewLocalHTTPError(http.StatusBadRequest, CodeArgInvalid, "error.validation", "validation failed")
+	//ErrUnauthorized is the error of not login
+	ErrUnauthorized = NewLocalHTTPError(http.StatusUnauthorized, CodeNotAccess, "error.unauthorized", "unauthorized")
+	//ErrForbidden is the error of not access
+	ErrForbidden = NewLocalHTTPError(http.StatusForbidden, CodeNotAccess, "error.forbidden", "forbidden")
+	//ErrNotFound is the error of not found
+	ErrNotFound = NewLocalHTTPError(http.StatusNotFound, CodeNotFound, "error.not_found", "not found")
+	//ErrMethodNotAllowed is the error of method not allowed
+	ErrMethodNotAllowed = NewLocalHTTPError(http.StatusMethodNotAllowed, CodeArgInvalid, "error.method_not_allowed", "method not allowed")
+	//ErrConflict is the error of resource conflict
+	ErrConflict = NewLocalHTTPError(http.StatusConflict, CodeArgInvalid, "error.conflict", "conflict")
+	//ErrRequestTooLarge is the error of request body too large
+	ErrRequestTooLarge = NewLocalHTTPError(http.StatusRequestEntityTooLarge, CodeArgInvalid, "error.request_too_large", "request entity too large")
+	//ErrTooManyRequests is the error of rate limited
+	ErrTooManyRequests = NewLocalHTTPError(http.StatusTooManyRequests, CodeNotAccess, "error.too_many_requests", "too many requests")
+	//ErrInternal is the error of server error
+	ErrInternal = NewLocalHTTPError(http.StatusInternalServerError, CodeServerError, "error.server_error", "internal server error")
+)
+
+// StatusPageKeys is the local key of built-in status page
+var StatusPageKeys = map[int]string{
+	http.StatusForbidden:             "error.forbidden",
+	http.StatusNotFound:              "error.not_found",
+	http.StatusMethodNotAllowed:      "error.method_not_allowed",
+	http.StatusRequestEntityTooLarge: "error.request_too_large",
+	http.StatusTooManyRequests:       "error.too_many_requests",
+}
+
+// SendStatusPage will send built-in plain text status page, the message is localized by StatusPageKeys, the status text is used when not found
+func (s *Session) SendStatusPage(status int) Result {
+	msg := http.StatusText(status)
+	if key, ok := StatusPageKeys[status]; ok {
+		if local := s.LocalValue(key); len(local) > 0 && local != key {
+			msg = local
+		}
+	}
+	http.Error(s.W, msg, status)
+	return Return
+}
+
+// PanicError is the error which is recovered from handler panic
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// ErrorMapper is the func to convert error to response
+type ErrorMapper func(s *Session, err error)
+
+// ErrorHandlerFunc is the handler func which return error, the returned error and panic is sent by mux ErrorMapper
+type ErrorHandlerFunc func(*Session) error
+
+// SrvHTTP is implement for web.Handler
+func (f ErrorHandlerFunc) SrvHTTP(s *Session) Result {
+	defer s.recoverPanic()
+	if err := f(s); err != nil {
+		s.SendErrorMapped(err)
+	}
+	return Return
+}
+
+// HandleErrorFunc will register error func as handler
+func (s *SessionMux) HandleErrorFunc(pattern string, h ErrorHandlerFunc) {
+	s.Handle(pattern, h)
+}
+
+// HandleMethodErrorFunc will register error func as handler
+func (s *SessionMux) HandleMethodErrorFunc(pattern string, h ErrorHandlerFunc, method string) {
+	s.HandleMethod(pattern, h, method)
+}
+
+// SendErrorMapped will send error by mux ErrorMapper, ProblemErrorMapper is used when it is not set
+func (s *Session) SendErrorMapped(err error) Result {
+	mapper := ProblemErrorMapper
+	if s.Mux != nil && s.Mux.ErrorMapper != nil {
+		mapper = s.Mux.ErrorMapper
+	}
+	mapper(s, err)
+	return Return
+}
+
+func (s *Session) isDebug() bool {
+	return s.Mux != nil && s.Mux.Debug
+}
+
+// TypedError will convert err to typed error, the validation error is converted to ErrValidation,
+// the request body too large error of http.MaxBytesReader is converted to ErrRequestTooLarge, the unknown error is converted to ErrInternal
+func TypedError(err error) (typed *HTTPError) {
+	if errors.As(err, &typed) {
+		return
+	}
+	if IsValidationError(err) {
+		typed = ErrValidation.Wrap(err)
+	} else if isMaxBytesError(err) {
+		typed = ErrRequestTooLarge.Wrap(err)
+	} else {
+		typed = ErrInternal.Wrap(err)
+	}
+	return
+}
+
+// ProblemErrorMapper will send error as RFC 7807 problem
+func ProblemErrorMapper(s *Session, err error) {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		s.SendProblem(problem)
+		return
+	}
+	typed := TypedError(err)
+	if typed.Err != nil && IsValidationError(typed.Err) {
+		problem = NewValidationProblem(typed.Err)
+	} else {
+		problem = NewProblem(typed.Status, typed.Message)
+	}
+	if key := typed.localKey(); len(key) > 0 {
+		if msg := s.LocalValue(key); len(msg) > 0 && msg != key {
+			problem.Detail = msg
+		}
+	}
+	problem.With("code", typed.Code)
+	if s.isDebug() && (error(typed) != err || typed.Err != nil) {
+		problem.With("debug", err.Error())
+	}
+	s.SendProblem(problem)
+}
+
+// ResultErrorMapper will send error as api result envelope, the message is localized by error key or registered code key
+func ResultErrorMapper(s *Session, err error) {
+	var problem *Problem
+	var typed *HTTPError
+	if errors.As(err, &problem) {
+		typed = &HTTPError{Status: problem.Status, Code: CodeServerError, Message: problem.Title, Err: err}
+	} else {
+		typed = TypedError(err)
+	}
+	msg := typed.Message
+	if key := typed.localKey(); len(key) > 0 {
+		msg = s.LocalValue(key)
+	}
+	var ext interface{}
+	if typed.Err != nil && IsValidationError(typed.Err) {
+		ext = NewValidationProblem(typed.Err).Extensions
+	}
+	s.sendError(typed.Status, typed.Code, msg, err, ext)
+}
+
+// recoverPanic will recover handler panic and send it by ErrorMapper, http.ErrAbortHandler is panic again
+func (s *Session) recoverPanic() {
+	val := recover()
+	if val == nil {
+		return
+	}
+	if val == http.ErrAbortHandler {
+		panic(val)
+	}
+	err := &PanicError{Value: val, Stack: debug.Stack()}
+	ErrorLog("SessionMux handle %v panic with %v\n%s", s.R.URL.Path, val, err.Stack)
+	s.SendErrorMapped(err)
+}
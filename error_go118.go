@@ -0,0 +1,11 @@
+//go:build !go1.19
+
+package web
+
+import "strings"
+
+// isMaxBytesError will check if err is returned by http.MaxBytesReader when the body is too large,
+// the http.MaxBytesError is not provided before go1.19, so the error message is checked
+func isMaxBytesError(err error) bool {
+	return strings.Contains(err.Error(), "http: request body too large")
+}
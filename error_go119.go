@@ -0,0 +1,14 @@
+//go:build go1.19
+
+package web
+
+import (
+	"errors"
+	"net/http"
+)
+
+// isMaxBytesError will check if err is returned by http.MaxBytesReader when the body is too large
+func isMaxBytesError(err error) bool {
+	var maxBytes *http.MaxBytesError
+	return errors.As(err, &maxBytes)
+}
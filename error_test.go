@@ -0,0 +1,140 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestErrorHandler(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.HandleErrorFunc("^/ok$", func(s *Session) error {
+		s.SendResult("ok")
+		return nil
+	})
+	mux.HandleErrorFunc("^/notfound$", func(s *Session) error {
+		return fmt.Errorf("find user 1: %w", ErrNotFound)
+	})
+	mux.HandleMethodErrorFunc("^/forbidden$", func(s *Session) error {
+		return ErrForbidden.Wrap(fmt.Errorf("not owner"))
+	}, "GET")
+	mux.Handle("^/typed$", ErrorHandlerFunc(func(s *Session) error {
+		return NewHTTPError(http.StatusPaymentRequired, 1002, "balance not enough")
+	}))
+	mux.HandleErrorFunc("^/valid$", func(s *Session) error {
+		var name string
+		return s.ValidFormat(`name,R|S,L:2~8;`, &name)
+	})
+	mux.HandleErrorFunc("^/problem$", func(s *Session) error {
+		return NewProblem(http.StatusTeapot, "tea")
+	})
+	mux.HandleErrorFunc("^/unknown$", func(s *Session) error {
+		return fmt.Errorf("db error")
+	})
+	mux.HandleErrorFunc("^/panic$", func(s *Session) error {
+		panic("crash")
+	})
+	mux.HandleErrorFunc("^/abort$", func(s *Session) error {
+		panic(http.ErrAbortHandler)
+	})
+	mux.HandleFunc("^/crash$", func(s *Session) Result {
+		panic("crash")
+	})
+	ts := httptest.NewServer(mux)
+	request := func(path string) (res xmap.M, status int) {
+		text, resp, err := xhttp.GetHeaderText(nil, "%v%v", ts.URL, path)
+		if err != nil {
+			return
+		}
+		res, _ = xmap.MapVal(text)
+		status = resp.StatusCode
+		return
+	}
+	//problem mapper
+	if res, status := request("/ok"); status != 200 || res.Str("data") != "ok" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/notfound"); status != 404 || res.Int("code") != CodeNotFound || res.Exist("debug") {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/forbidden"); status != 403 || res.Str("detail") != "forbidden" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/typed"); status != 402 || res.Int("code") != 1002 || res.Str("detail") != "balance not enough" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/valid?name=a"); status != 400 || len(res.ArrayMapDef(nil, "errors")) != 1 {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/problem"); status != 418 || res.Str("detail") != "tea" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/unknown"); status != 500 || strings.Contains(res.Str("debug"), "db error") {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/panic"); status != 500 || res.Int("code") != CodeServerError {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if _, err := xhttp.GetText("%v/abort", ts.URL); err == nil {
+		t.Error(err)
+		return
+	}
+	if _, err := xhttp.GetText("%v/crash", ts.URL); err == nil {
+		t.Error(err)
+		return
+	}
+	mux.Debug = true
+	if res, status := request("/unknown"); status != 500 || res.Str("debug") != "db error" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/panic"); status != 500 || res.Str("debug") != "panic: crash" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	//result mapper
+	mux.ErrorMapper = ResultErrorMapper
+	if res, status := request("/notfound"); status != 404 || res.Int("code") != CodeNotFound || res.Str("msg") != "error.not_found" || !strings.Contains(res.Str("dmsg"), "find user 1") {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/typed"); status != 402 || res.Int("code") != 1002 || res.Str("msg") != "balance not enough" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/valid?name=a"); status != 400 || res.Int("code") != CodeArgInvalid || len(res.ArrayMapDef(nil, "ext/errors")) != 1 {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/problem"); status != 418 || res.Int("code") != CodeServerError {
+		t.Errorf("res:%v", res)
+		return
+	}
+	if res, status := request("/panic"); status != 500 || res.Str("dmsg") != "panic: crash" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	//sentinel
+	if !errors.Is(ErrNotFound.Wrap(fmt.Errorf("x")), ErrNotFound) || errors.Is(ErrNotFound.Wrap(fmt.Errorf("x")), ErrForbidden) || ErrNotFound.Error() != "not found" {
+		t.Error("error")
+		return
+	}
+	if typed := TypedError(fmt.Errorf("x")); typed.Status != 500 || !errors.Is(typed, ErrInternal) {
+		t.Error("error")
+		return
+	}
+}
@@ -45,6 +45,18 @@ func (c *CORS) exec(w http.ResponseWriter, r *http.Request) web.Result {
 	}
 }
 
+// CheckOrigin will check if origin is allowed by sites, it is implement for web.OriginChecker
+func (c *CORS) CheckOrigin(origin string) bool {
+	if v, ok := c.Sites["@"]; ok && v > 0 {
+		return true
+	} else if v, ok := c.Sites["*"]; ok && v > 0 {
+		return true
+	} else if v, ok := c.Sites[origin]; ok && v > 0 {
+		return true
+	}
+	return false
+}
+
 func (c *CORS) SrvHTTP(s *web.Session) web.Result {
 	return c.exec(s.W, s.R)
 }
@@ -71,3 +71,16 @@ func TestAllCors(t *testing.T) {
 		return
 	}
 }
+
+func TestCorsCheckOrigin(t *testing.T) {
+	cors := NewSiteCORS("http://a.com")
+	if !cors.CheckOrigin("http://a.com") || cors.CheckOrigin("http://b.com") {
+		t.Error("error")
+		return
+	}
+	var checker web.OriginChecker = NewAllCORS()
+	if !checker.CheckOrigin("http://b.com") || !NewOriginCORS().CheckOrigin("http://b.com") {
+		t.Error("error")
+		return
+	}
+}
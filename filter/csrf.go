@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/codingeasygo/web"
+)
+
+// CSRF is filter to protect unsafe request by token, the token is stored in session value by default,
+// or stored in cookie when DoubleSubmit is enabled
+type CSRF struct {
+	Key          string   //the session value key to store token
+	Header       string   //the request header to read token
+	Field        string   //the form field to read token
+	Cookie       string   //the cookie name on double submit mode, it is written and read by mux cookie option
+	DoubleSubmit bool     //enable double submit cookie mode for stateless setup
+	SafeMethods  []string //the method not to check token
+}
+
+// NewCSRF will return new CSRF filter by session token
+func NewCSRF() *CSRF {
+	return &CSRF{
+		Key:         "_csrf_",
+		Header:      "X-CSRF-Token",
+		Field:       "_csrf",
+		Cookie:      "csrf_token",
+		SafeMethods: []string{"GET", "HEAD", "OPTIONS", "TRACE"},
+	}
+}
+
+// NewDoubleSubmitCSRF will return new CSRF filter by double submit cookie
+func NewDoubleSubmitCSRF() *CSRF {
+	csrf := NewCSRF()
+	csrf.DoubleSubmit = true
+	return csrf
+}
+
+func (c *CSRF) newToken() string {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func (c *CSRF) loadToken(hs *web.Session) (token string) {
+	if c.DoubleSubmit {
+		if v, ok := hs.Var(c.Cookie).(string); ok {
+			token = v
+		} else {
+			token = hs.Cookie(c.Cookie)
+		}
+	} else {
+		token = hs.StrDef("", c.Key)
+	}
+	return
+}
+
+// Token will return the token of session, it will create new token if not exists
+func (c *CSRF) Token(hs *web.Session) (token string) {
+	token = c.loadToken(hs)
+	if len(token) > 0 {
+		return
+	}
+	token = c.newToken()
+	if c.DoubleSubmit {
+		option := hs.MuxCookieOption()
+		option.MaxAge, option.HttpOnly = 0, false
+		if option.SameSite == 0 {
+			option.SameSite = http.SameSiteLaxMode
+		}
+		hs.SetCookieOption(c.Cookie, token, option)
+		hs.SetVar(c.Cookie, token)
+	} else {
+		hs.SetValue(c.Key, token)
+	}
+	return
+}
+
+func (c *CSRF) isSafe(method string) bool {
+	for _, m := range c.SafeMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// SrvHTTP is implement for web.Handler
+func (c *CSRF) SrvHTTP(hs *web.Session) web.Result {
+	if c.isSafe(hs.R.Method) {
+		return web.Continue
+	}
+	expect := c.loadToken(hs)
+	having := hs.R.Header.Get(c.Header)
+	if len(having) < 1 {
+		having = hs.Argument(c.Field)
+	}
+	if len(expect) < 1 || subtle.ConstantTimeCompare([]byte(expect), []byte(having)) != 1 {
+		web.WarnLog("CSRF check token fail on %v %v", hs.R.Method, hs.R.URL.Path)
+		return hs.SendStatusPage(http.StatusForbidden)
+	}
+	return web.Continue
+}
+
+// Funcs will return template funcs for render, it provide csrfToken/csrfField
+func (c *CSRF) Funcs(hs *web.Session) template.FuncMap {
+	return template.FuncMap{
+		"csrfToken": func() string {
+			return c.Token(hs)
+		},
+		"csrfField": func() template.HTML {
+			field := fmt.Sprintf(`<input type="hidden" name="%v" value="%v">`, template.HTMLEscapeString(c.Field), template.HTMLEscapeString(c.Token(hs)))
+			return template.HTML(field)
+		},
+	}
+}
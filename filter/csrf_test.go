@@ -0,0 +1,168 @@
+package filter
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+	"github.com/codingeasygo/web"
+	"github.com/codingeasygo/web/httptest"
+)
+
+func TestCSRF(t *testing.T) {
+	xhttp.EnableCookie()
+	defer xhttp.DisableCookie()
+	csrf := NewCSRF()
+	ts := httptest.NewMuxServer()
+	ts.Mux.INT, _ = web.NewFSJSONINT(fstest.MapFS{
+		"en.json": {Data: []byte(`{"error.forbidden":"token invalid"}`)},
+	})
+	ts.Mux.Filter("^.*$", csrf)
+	ts.Mux.HandleFunc("^/token$", func(s *web.Session) web.Result {
+		return s.SendPlainText(csrf.Token(s))
+	})
+	ts.Mux.HandleFunc("^/field$", func(s *web.Session) web.Result {
+		return s.SendPlainText(string(csrf.Funcs(s)["csrfField"].(func() template.HTML)()))
+	})
+	ts.Mux.HandleFunc("^/post$", func(s *web.Session) web.Result {
+		return s.SendPlainText("ok")
+	})
+	token, err := ts.GetText("/token")
+	if err != nil || len(token) < 1 {
+		t.Errorf("err:%v,token:%v", err, token)
+		return
+	}
+	token2, _ := ts.GetText("/token")
+	if token != token2 {
+		t.Errorf("%v,%v", token, token2)
+		return
+	}
+	field, _ := ts.GetText("/field")
+	if !strings.Contains(field, token) {
+		t.Error(field)
+		return
+	}
+	text, _, err := ts.PostHeaderText(xmap.M{"X-CSRF-Token": token}, nil, "/post")
+	if err != nil || text != "ok" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, err = ts.PostFormText(xmap.M{"_csrf": token}, "/post")
+	if err != nil || text != "ok" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, res, err := ts.PostHeaderText(xmap.M{"X-CSRF-Token": "xx"}, nil, "/post")
+	if err != nil || res.StatusCode != http.StatusForbidden || strings.TrimSpace(text) != "token invalid" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	_, err = ts.PostFormText(nil, "/post")
+	if err == nil {
+		t.Error(err)
+		return
+	}
+}
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	xhttp.EnableCookie()
+	defer xhttp.DisableCookie()
+	csrf := NewDoubleSubmitCSRF()
+	ts := httptest.NewMuxServer()
+	ts.Mux.Filter("^.*$", csrf)
+	ts.Mux.HandleFunc("^/token$", func(s *web.Session) web.Result {
+		return s.SendPlainText(csrf.Token(s) + "," + csrf.Token(s))
+	})
+	ts.Mux.HandleFunc("^/post$", func(s *web.Session) web.Result {
+		return s.SendPlainText("ok")
+	})
+	tokens, err := ts.GetText("/token")
+	parts := strings.Split(tokens, ",")
+	if err != nil || len(parts) != 2 || parts[0] != parts[1] {
+		t.Errorf("err:%v,tokens:%v", err, tokens)
+		return
+	}
+	token := parts[0]
+	text, _, err := ts.PostHeaderText(xmap.M{"X-CSRF-Token": token}, nil, "/post")
+	if err != nil || text != "ok" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	_, res, err := ts.PostHeaderText(xmap.M{"X-CSRF-Token": "xx"}, nil, "/post")
+	if err != nil || res.StatusCode != http.StatusForbidden {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+}
+
+func TestCSRFHostPrefix(t *testing.T) {
+	csrf := NewDoubleSubmitCSRF()
+	ts := httptest.NewMuxServer()
+	ts.Mux.Cookie.HostPrefix = true
+	ts.Mux.Filter("^.*$", csrf)
+	ts.Mux.HandleFunc("^/token$", func(s *web.Session) web.Result {
+		return s.SendPlainText(csrf.Token(s))
+	})
+	ts.Mux.HandleFunc("^/post$", func(s *web.Session) web.Result {
+		return s.SendPlainText("ok")
+	})
+	token, res, err := ts.GetHeaderText(nil, "/token")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cookies := res.Header.Values("Set-Cookie"); cookies[len(cookies)-1] != "__Host-csrf_token="+token+"; Path=/; Secure; SameSite=Lax" {
+		t.Errorf("cookies:%v", cookies)
+		return
+	}
+	text, _, err := ts.PostHeaderText(xmap.M{"Cookie": "__Host-csrf_token=" + token, "X-CSRF-Token": token}, nil, "/post")
+	if err != nil || text != "ok" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+}
+
+func TestCSRFRender(t *testing.T) {
+	xhttp.EnableCookie()
+	defer xhttp.DisableCookie()
+	csrf := NewCSRF()
+	rn := NewRenderDataNamedHandler()
+	rn.AddFunc("/csrf", func(r *Render, hs *web.Session, tmpl *Template, args url.Values, info interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	r := NewRender(".", rn)
+	r.CacheDir = ""
+	r.AddSessionFuncs(csrf.Funcs)
+	ts := httptest.NewMuxServer()
+	ts.Mux.HandleFunc("^/token$", func(s *web.Session) web.Result {
+		return s.SendPlainText(csrf.Token(s))
+	})
+	ts.Mux.Handle("^.*$", r)
+	token, err := ts.GetText("/token")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	assertGet(ts, token, true, "/render_test7.html")
+	if len(r.latest) != 0 {
+		t.Errorf("latest:%v", r.latest)
+		return
+	}
+	if _, err = r.LoadTemplate("render_test7.html"); err == nil {
+		t.Error(err)
+		return
+	}
+	if tmpl, err := r.LoadSessionTemplate(&web.Session{}, "render_test7.html"); err != nil || !tmpl.Session {
+		t.Error(err)
+		return
+	}
+	if tmpl, err := r.LoadSessionTemplate(&web.Session{}, "render_test1.html"); err != nil || tmpl.Session {
+		t.Error(err)
+		return
+	}
+}
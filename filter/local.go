@@ -0,0 +1,19 @@
+package filter
+
+import (
+	"html/template"
+
+	"github.com/codingeasygo/web"
+)
+
+// LocalFuncs will return template funcs for render, it provide local/locale by session international
+func LocalFuncs(hs *web.Session) template.FuncMap {
+	return template.FuncMap{
+		"local": func(key string, args ...interface{}) string {
+			return hs.LocalValue(key, args...)
+		},
+		"locale": func() string {
+			return hs.Locale()
+		},
+	}
+}
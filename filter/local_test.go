@@ -0,0 +1,35 @@
+package filter
+
+import (
+	"html/template"
+	"net/url"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codingeasygo/web"
+	"github.com/codingeasygo/web/httptest"
+)
+
+func TestLocalRender(t *testing.T) {
+	inter, err := web.NewFSJSONINT(fstest.MapFS{
+		"en.json": {Data: []byte(`{"abc":"en value","hello":"hello {name}"}`)},
+		"zh.json": {Data: []byte(`{"abc":"zh value"}`)},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rn := NewRenderDataNamedHandler()
+	rn.AddFunc("/local", func(r *Render, hs *web.Session, tmpl *Template, args url.Values, info interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	r := NewRender(".", rn)
+	r.CacheErr = false
+	ts := httptest.NewMuxServer()
+	ts.Mux.INT = inter
+	ts.Mux.Handle("^.*$", r)
+	assertGet(ts, "en:en value:hello x", true, "/render_test9.html")
+	assertGet(ts, "zh:zh value:hello x", true, "/render_test9.html?lang=zh")
+	r.Funcs = template.FuncMap{"locale": func() string { return "custom" }}
+	assertGet(ts, "custom:en value:hello x", true, "/render_test9.html")
+}
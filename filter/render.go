@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"text/template/parse"
 
 	"github.com/codingeasygo/util/converter"
 	"github.com/codingeasygo/util/xhttp"
@@ -112,6 +113,9 @@ func (r *RenderDataNamedHandler) LoadData(reander *Render, hs *web.Session) (tmp
 	return
 }
 
+// RenderFuncs is func to load template funcs by http session
+type RenderFuncs func(hs *web.Session) template.FuncMap
+
 // Template is reander template
 type Template struct {
 	Path     string             `json:"path"`
@@ -119,6 +123,7 @@ type Template struct {
 	Key      string             `json:"key"`
 	URL      *url.URL           `json:"-"`
 	Template *template.Template `json:"-"`
+	Session  bool               `json:"-"` //the template calls funcs from Loaders, the output is not cached
 }
 
 // Render is http web page render on server
@@ -127,17 +132,19 @@ type Render struct {
 	Handler   RenderHandler
 	ErrorPage string
 	Funcs     template.FuncMap
+	Loaders   []RenderFuncs
 	CacheErr  bool
 	CacheDir  string
 	latest    map[string][]byte
 	cacheLck  sync.RWMutex
 }
 
-// NewRender will create reander by handler
+// NewRender will create reander by handler, the LocalFuncs is loaded by default
 func NewRender(dir string, h RenderHandler) *Render {
 	return &Render{
 		Dir:      dir,
 		Handler:  h,
+		Loaders:  []RenderFuncs{LocalFuncs},
 		CacheErr: true,
 		CacheDir: os.TempDir(),
 		latest:   map[string][]byte{},
@@ -145,8 +152,100 @@ func NewRender(dir string, h RenderHandler) *Render {
 	}
 }
 
-// LoadTemplate will create load template from path
+// AddSessionFuncs will register funcs loader which is called by each http session
+func (r *Render) AddSessionFuncs(f RenderFuncs) {
+	r.Loaders = append(r.Loaders, f)
+}
+
+// SessionFuncs will return all template funcs for http session, the Funcs is override the funcs from Loaders
+func (r *Render) SessionFuncs(hs *web.Session) (funcs template.FuncMap) {
+	funcs, _ = r.sessionFuncs(hs)
+	return
+}
+
+func (r *Render) sessionFuncs(hs *web.Session) (funcs template.FuncMap, session map[string]bool) {
+	funcs, session = template.FuncMap{}, map[string]bool{}
+	for _, loader := range r.Loaders {
+		for key, f := range loader(hs) {
+			funcs[key], session[key] = f, true
+		}
+	}
+	for key, f := range r.Funcs {
+		funcs[key] = f
+		delete(session, key)
+	}
+	return
+}
+
+// LoadTemplate will create load template from path, only Funcs is registered because there is no http session,
+// using LoadSessionTemplate to register funcs from Loaders
 func (r *Render) LoadTemplate(path string) (tmpl *Template, err error) {
+	tmpl, err = r.loadTemplate(path, r.Funcs)
+	return
+}
+
+// LoadSessionTemplate will create load template from path with all funcs for http session,
+// the Session is true when the template calls funcs from Loaders
+func (r *Render) LoadSessionTemplate(hs *web.Session, path string) (tmpl *Template, err error) {
+	funcs, session := r.sessionFuncs(hs)
+	tmpl, err = r.loadTemplate(path, funcs)
+	if err == nil {
+		for _, t := range tmpl.Template.Templates() {
+			if t.Tree != nil && callFuncs(t.Tree.Root, session) {
+				tmpl.Session = true
+				break
+			}
+		}
+	}
+	return
+}
+
+// callFuncs will check if the template node calls any of funcs
+func callFuncs(node parse.Node, funcs map[string]bool) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if callFuncs(child, funcs) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return callFuncs(n.Pipe, funcs)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if callFuncs(cmd, funcs) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if callFuncs(arg, funcs) {
+				return true
+			}
+		}
+	case *parse.ChainNode:
+		return callFuncs(n.Node, funcs)
+	case *parse.IdentifierNode:
+		return funcs[n.Ident]
+	case *parse.IfNode:
+		return callFuncs(n.Pipe, funcs) || callFuncs(n.List, funcs) || callFuncs(n.ElseList, funcs)
+	case *parse.RangeNode:
+		return callFuncs(n.Pipe, funcs) || callFuncs(n.List, funcs) || callFuncs(n.ElseList, funcs)
+	case *parse.WithNode:
+		return callFuncs(n.Pipe, funcs) || callFuncs(n.List, funcs) || callFuncs(n.ElseList, funcs)
+	case *parse.TemplateNode:
+		return callFuncs(n.Pipe, funcs)
+	}
+	return false
+}
+
+func (r *Render) loadTemplate(path string, funcs template.FuncMap) (tmpl *Template, err error) {
 	tmpl = &Template{}
 	tmpl.Path = path
 	filename := filepath.Join(r.Dir, path)
@@ -171,8 +270,8 @@ func (r *Render) LoadTemplate(path string) (tmpl *Template, err error) {
 		tmpl.Key = tmpl.URL.Path
 	}
 	stdtmpl := template.New(tmpl.Path)
-	if r.Funcs != nil {
-		stdtmpl = stdtmpl.Funcs(r.Funcs)
+	if len(funcs) > 0 {
+		stdtmpl = stdtmpl.Funcs(funcs)
 	}
 	tmpl.Template, err = stdtmpl.Parse(tmpl.Text)
 	return
@@ -185,7 +284,7 @@ func (r *Render) LoadSession(hs *web.Session) (*Template, url.Values, error) {
 	if len(path) < 1 {
 		path = "index.html"
 	}
-	tmpl, err := r.LoadTemplate(path)
+	tmpl, err := r.LoadSessionTemplate(hs, path)
 	if err != nil {
 		return nil, nil, fmt.Errorf("loading template fail->%v", err)
 	}
@@ -209,11 +308,13 @@ func (r *Render) SrvHTTP(hs *web.Session) web.Result {
 		return hs.Printf("load data fail with %v", err)
 	}
 	buffer := bytes.NewBuffer(nil)
-	_, _, err := r.prepareResponseData(buffer, hs)
+	tmpl, _, err := r.prepareResponseData(buffer, hs)
 	if err == nil {
 		cache := buffer.Bytes()
 		hs.W.Write(cache)
-		err = r.storeCacheData(hs, cache)
+		if !tmpl.Session { //the output by session funcs is not shared to other session
+			err = r.storeCacheData(hs, cache)
+		}
 		if err != nil {
 			web.ErrorLog("Render store cache data fail with %v", err)
 		}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+	"strings"
+
+	"github.com/codingeasygo/web"
+)
+
+// CSPNonce is the placeholder of Content-Security-Policy which will be replaced by per-request nonce
+const CSPNonce = "{nonce}"
+
+const cspNonceKey = "_csp_nonce_"
+
+// SecureHeaders is filter to set security response headers, empty value header is not set.
+//
+// the CSP can contain {nonce} placeholder, it will be replaced by per-request nonce,
+// and template can use cspNonce func to reach it.
+type SecureHeaders struct {
+	HSTS               string //Strict-Transport-Security
+	ContentTypeOptions string //X-Content-Type-Options
+	FrameOptions       string //X-Frame-Options
+	ReferrerPolicy     string //Referrer-Policy
+	PermissionsPolicy  string //Permissions-Policy
+	CSP                string //Content-Security-Policy
+}
+
+// NewSecureHeaders will return new SecureHeaders with common default
+func NewSecureHeaders() *SecureHeaders {
+	return &SecureHeaders{
+		HSTS:               "max-age=31536000; includeSubDomains",
+		ContentTypeOptions: "nosniff",
+		FrameOptions:       "SAMEORIGIN",
+		ReferrerPolicy:     "strict-origin-when-cross-origin",
+	}
+}
+
+// NewAPISecureHeaders will return new SecureHeaders preset for api route, which is not allowed to render anything
+func NewAPISecureHeaders() *SecureHeaders {
+	secure := NewSecureHeaders()
+	secure.FrameOptions = "DENY"
+	secure.ReferrerPolicy = "no-referrer"
+	secure.CSP = "default-src 'none'; frame-ancestors 'none'"
+	return secure
+}
+
+// NewHTMLSecureHeaders will return new SecureHeaders preset for html route, which script is allowed by nonce
+func NewHTMLSecureHeaders() *SecureHeaders {
+	secure := NewSecureHeaders()
+	secure.PermissionsPolicy = "camera=(), microphone=(), geolocation=()"
+	secure.CSP = "default-src 'self'; script-src 'self' 'nonce-" + CSPNonce + "'; style-src 'self' 'nonce-" + CSPNonce + "'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'"
+	return secure
+}
+
+// Nonce will return the per-request nonce, it will create new nonce if not exists
+func (s *SecureHeaders) Nonce(hs *web.Session) (nonce string) {
+	if v, ok := hs.Var(cspNonceKey).(string); ok {
+		nonce = v
+		return
+	}
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		panic(err)
+	}
+	nonce = base64.RawURLEncoding.EncodeToString(buf)
+	hs.SetVar(cspNonceKey, nonce)
+	return
+}
+
+// SrvHTTP is implement for web.Handler
+func (s *SecureHeaders) SrvHTTP(hs *web.Session) web.Result {
+	header := hs.W.Header()
+	if len(s.HSTS) > 0 {
+		header.Set("Strict-Transport-Security", s.HSTS)
+	}
+	if len(s.ContentTypeOptions) > 0 {
+		header.Set("X-Content-Type-Options", s.ContentTypeOptions)
+	}
+	if len(s.FrameOptions) > 0 {
+		header.Set("X-Frame-Options", s.FrameOptions)
+	}
+	if len(s.ReferrerPolicy) > 0 {
+		header.Set("Referrer-Policy", s.ReferrerPolicy)
+	}
+	if len(s.PermissionsPolicy) > 0 {
+		header.Set("Permissions-Policy", s.PermissionsPolicy)
+	}
+	if len(s.CSP) > 0 {
+		csp := s.CSP
+		if strings.Contains(csp, CSPNonce) {
+			csp = strings.ReplaceAll(csp, CSPNonce, s.Nonce(hs))
+		}
+		header.Set("Content-Security-Policy", csp)
+	}
+	return web.Continue
+}
+
+// Funcs will return template funcs for render, it provide cspNonce
+func (s *SecureHeaders) Funcs(hs *web.Session) template.FuncMap {
+	return template.FuncMap{
+		"cspNonce": func() string {
+			return s.Nonce(hs)
+		},
+	}
+}
@@ -0,0 +1,53 @@
+package filter
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/codingeasygo/web"
+	"github.com/codingeasygo/web/httptest"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	api := NewAPISecureHeaders()
+	html := NewHTMLSecureHeaders()
+	rn := NewRenderDataNamedHandler()
+	rn.AddFunc("/secure", func(r *Render, hs *web.Session, tmpl *Template, args url.Values, info interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	r := NewRender(".", rn)
+	r.CacheErr = false
+	r.AddSessionFuncs(html.Funcs)
+	ts := httptest.NewMuxServer()
+	ts.Mux.Filter("^/api/.*$", api)
+	ts.Mux.Filter("^/.*\\.html$", html)
+	ts.Mux.HandleFunc("^/api/.*$", func(s *web.Session) web.Result {
+		return s.SendPlainText("ok")
+	})
+	ts.Mux.Handle("^.*$", r)
+	_, res, err := ts.GetHeaderText(nil, "/api/test")
+	if err != nil || res.Header.Get("X-Frame-Options") != "DENY" || res.Header.Get("Content-Security-Policy") != api.CSP {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	if res.Header.Get("X-Content-Type-Options") != "nosniff" || len(res.Header.Get("Strict-Transport-Security")) < 1 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	nonce, res, err := ts.GetHeaderText(nil, "/render_test8.html")
+	nonce = strings.TrimSpace(nonce)
+	if err != nil || len(nonce) < 1 || !strings.Contains(res.Header.Get("Content-Security-Policy"), "'nonce-"+nonce+"'") {
+		t.Errorf("err:%v,nonce:%v,res:%v", err, nonce, res.Header)
+		return
+	}
+	if len(res.Header.Get("Permissions-Policy")) < 1 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	nonce2, _, _ := ts.GetHeaderText(nil, "/render_test8.html")
+	if nonce == strings.TrimSpace(nonce2) {
+		t.Errorf("nonce:%v,%v", nonce, nonce2)
+		return
+	}
+}
@@ -0,0 +1,385 @@
+package web
+
+import (
+	"sync"
+)
+
+// HubMessage is the message delivered by hub, Session/User is set when it is sent to special session or user,
+// or it is published to Topic
+type HubMessage struct {
+	Topic   string      `json:"topic,omitempty"`
+	Session string      `json:"session,omitempty"`
+	User    string      `json:"user,omitempty"`
+	Event   string      `json:"event,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// HubBroadcaster is interface to broadcast message to all hub nodes, the multi-node backend can be implemented by message queue
+type HubBroadcaster interface {
+	//Broadcast will send message to all nodes
+	Broadcast(msg *HubMessage) error
+	//Subscribe will register the deliver func to receive message from all nodes
+	Subscribe(deliver func(msg *HubMessage))
+}
+
+// HubMemBroadcaster is memory implement of HubBroadcaster which only deliver message in current process
+type HubMemBroadcaster struct {
+	delivers []func(msg *HubMessage)
+	locker   sync.RWMutex
+}
+
+// NewHubMemBroadcaster will return new memory broadcaster
+func NewHubMemBroadcaster() *HubMemBroadcaster {
+	return &HubMemBroadcaster{
+		locker: sync.RWMutex{},
+	}
+}
+
+// Broadcast will send message to all subscribed deliver
+func (m *HubMemBroadcaster) Broadcast(msg *HubMessage) (err error) {
+	m.locker.RLock()
+	delivers := m.delivers
+	m.locker.RUnlock()
+	for _, deliver := range delivers {
+		deliver(msg)
+	}
+	return
+}
+
+// Subscribe will register the deliver func
+func (m *HubMemBroadcaster) Subscribe(deliver func(msg *HubMessage)) {
+	m.locker.Lock()
+	m.delivers = append(m.delivers, deliver)
+	m.locker.Unlock()
+}
+
+// HubClient is the client connected to hub, it is indexed by session id and user key
+type HubClient struct {
+	Session   string
+	User      string
+	Evicted   bool //if client is evicted by slow consumer
+	hub       *Hub
+	topics    map[string]bool
+	queue     chan *HubMessage
+	done      chan struct{}
+	locker    sync.Mutex
+	closeOnce sync.Once
+}
+
+// Subscribe will subscribe topics
+func (c *HubClient) Subscribe(topics ...string) {
+	c.hub.subscribe(c, topics...)
+}
+
+// Unsubscribe will unsubscribe topics
+func (c *HubClient) Unsubscribe(topics ...string) {
+	c.hub.unsubscribe(c, topics...)
+}
+
+// Topics will return the subscribed topics
+func (c *HubClient) Topics() (topics []string) {
+	c.hub.locker.RLock()
+	defer c.hub.locker.RUnlock()
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	return
+}
+
+// Receive will return the message queue channel
+func (c *HubClient) Receive() <-chan *HubMessage {
+	return c.queue
+}
+
+// Done will return the channel which is closed when client leaved or evicted
+func (c *HubClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close will leave client from hub
+func (c *HubClient) Close() (err error) {
+	c.hub.Leave(c)
+	return
+}
+
+func (c *HubClient) push(msg *HubMessage) (evicted bool) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.queue <- msg:
+	default:
+		c.Evicted = true
+		evicted = true
+		c.closeDone()
+	}
+	return
+}
+
+func (c *HubClient) closeDone() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Hub is topic based pub/sub hub for live connection, like SSE and WebSocket
+type Hub struct {
+	QueueSize   int                     //the max queue size of each client, the slow consumer is evicted when queue is full
+	OnEvict     func(client *HubClient) //the callback when client is evicted
+	ShowLog     bool
+	Broadcaster HubBroadcaster
+	clients     map[*HubClient]bool
+	topics      map[string]map[*HubClient]bool
+	sessions    map[string]map[*HubClient]bool
+	users       map[string]map[*HubClient]bool
+	locker      sync.RWMutex
+}
+
+// NewHub will return new hub by memory broadcaster
+func NewHub() *Hub {
+	return NewBroadcasterHub(NewHubMemBroadcaster())
+}
+
+// NewBroadcasterHub will return new hub by broadcaster
+func NewBroadcasterHub(broadcaster HubBroadcaster) (hub *Hub) {
+	hub = &Hub{
+		QueueSize:   64,
+		Broadcaster: broadcaster,
+		clients:     map[*HubClient]bool{},
+		topics:      map[string]map[*HubClient]bool{},
+		sessions:    map[string]map[*HubClient]bool{},
+		users:       map[string]map[*HubClient]bool{},
+		locker:      sync.RWMutex{},
+	}
+	broadcaster.Subscribe(hub.deliver)
+	return
+}
+
+func (h *Hub) log(f string, args ...interface{}) {
+	if h.ShowLog {
+		DebugLog(f, args...)
+	}
+}
+
+func hubIndexAdd(index map[string]map[*HubClient]bool, key string, client *HubClient) {
+	if len(key) < 1 {
+		return
+	}
+	clients := index[key]
+	if clients == nil {
+		clients = map[*HubClient]bool{}
+		index[key] = clients
+	}
+	clients[client] = true
+}
+
+func hubIndexRemove(index map[string]map[*HubClient]bool, key string, client *HubClient) {
+	clients := index[key]
+	if clients == nil {
+		return
+	}
+	delete(clients, client)
+	if len(clients) < 1 {
+		delete(index, key)
+	}
+}
+
+// Join will add new client to hub by session id and user key, and subscribe topics
+func (h *Hub) Join(session, user string, topics ...string) (client *HubClient) {
+	client = &HubClient{
+		Session: session,
+		User:    user,
+		hub:     h,
+		topics:  map[string]bool{},
+		queue:   make(chan *HubMessage, h.QueueSize),
+		done:    make(chan struct{}),
+		locker:  sync.Mutex{},
+	}
+	h.locker.Lock()
+	h.clients[client] = true
+	hubIndexAdd(h.sessions, session, client)
+	hubIndexAdd(h.users, user, client)
+	h.locker.Unlock()
+	h.subscribe(client, topics...)
+	h.log("Hub client %v/%v is joined", session, user)
+	return
+}
+
+// Leave will remove client from hub
+func (h *Hub) Leave(client *HubClient) {
+	h.locker.Lock()
+	_, ok := h.clients[client]
+	if ok {
+		delete(h.clients, client)
+		hubIndexRemove(h.sessions, client.Session, client)
+		hubIndexRemove(h.users, client.User, client)
+		for topic := range client.topics {
+			hubIndexRemove(h.topics, topic, client)
+		}
+	}
+	h.locker.Unlock()
+	client.locker.Lock()
+	client.closeDone()
+	client.locker.Unlock()
+	if ok {
+		h.log("Hub client %v/%v is leaved", client.Session, client.User)
+	}
+}
+
+func (h *Hub) subscribe(client *HubClient, topics ...string) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	if !h.clients[client] {
+		return
+	}
+	for _, topic := range topics {
+		client.topics[topic] = true
+		hubIndexAdd(h.topics, topic, client)
+	}
+}
+
+func (h *Hub) unsubscribe(client *HubClient, topics ...string) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	for _, topic := range topics {
+		delete(client.topics, topic)
+		hubIndexRemove(h.topics, topic, client)
+	}
+}
+
+// Publish will publish message to topic
+func (h *Hub) Publish(topic, event string, data interface{}) (err error) {
+	err = h.Broadcaster.Broadcast(&HubMessage{Topic: topic, Event: event, Data: data})
+	return
+}
+
+// SendSession will send message to all clients of session
+func (h *Hub) SendSession(session, event string, data interface{}) (err error) {
+	err = h.Broadcaster.Broadcast(&HubMessage{Session: session, Event: event, Data: data})
+	return
+}
+
+// SendUser will send message to all clients of user
+func (h *Hub) SendUser(user, event string, data interface{}) (err error) {
+	err = h.Broadcaster.Broadcast(&HubMessage{User: user, Event: event, Data: data})
+	return
+}
+
+func (h *Hub) find(index map[string]map[*HubClient]bool, key string) (clients []*HubClient) {
+	h.locker.RLock()
+	defer h.locker.RUnlock()
+	for client := range index[key] {
+		clients = append(clients, client)
+	}
+	return
+}
+
+// SessionClients will return clients by session id
+func (h *Hub) SessionClients(session string) []*HubClient {
+	return h.find(h.sessions, session)
+}
+
+// UserClients will return clients by user key
+func (h *Hub) UserClients(user string) []*HubClient {
+	return h.find(h.users, user)
+}
+
+// TopicClients will return clients by topic
+func (h *Hub) TopicClients(topic string) []*HubClient {
+	return h.find(h.topics, topic)
+}
+
+// Size will return the number of clients
+func (h *Hub) Size() int {
+	h.locker.RLock()
+	defer h.locker.RUnlock()
+	return len(h.clients)
+}
+
+// deliver will push message to local clients, it is called by broadcaster
+func (h *Hub) deliver(msg *HubMessage) {
+	var clients []*HubClient
+	switch {
+	case len(msg.Session) > 0:
+		clients = h.SessionClients(msg.Session)
+	case len(msg.User) > 0:
+		clients = h.UserClients(msg.User)
+	default:
+		clients = h.TopicClients(msg.Topic)
+	}
+	for _, client := range clients {
+		if !client.push(msg) {
+			continue
+		}
+		h.log("Hub client %v/%v is evicted by slow consumer", client.Session, client.User)
+		h.Leave(client)
+		if h.OnEvict != nil {
+			h.OnEvict(client)
+		}
+	}
+}
+
+// ServeSSE will start server-sent events response on session and join client to hub,
+// it will send message to client until client leaved or disconnected
+func (h *Hub) ServeSSE(hs *Session, user string, topics ...string) (err error) {
+	writer, err := hs.SSE()
+	if err != nil {
+		return
+	}
+	client := h.Join(sessionID(hs), user, topics...)
+	defer client.Close()
+	for {
+		select {
+		case msg := <-client.Receive():
+			err = writer.Send(msg.Event, "", msg.Data)
+			if err != nil {
+				return
+			}
+		case <-client.Done():
+			writer.Close()
+			return
+		case <-writer.Done():
+			return
+		}
+	}
+}
+
+// ServeWebSocket will join client to hub and send message as json to websocket connection until client leaved or disconnected,
+// the incoming message from connection is ignored
+func (h *Hub) ServeWebSocket(hs *Session, conn *WebSocketConn, user string, topics ...string) (err error) {
+	client := h.Join(sessionID(hs), user, topics...)
+	defer client.Close()
+	go func() {
+		for {
+			if _, _, xerr := conn.ReadMessage(); xerr != nil {
+				conn.Close()
+				break
+			}
+		}
+	}()
+	for {
+		select {
+		case msg := <-client.Receive():
+			err = conn.WriteJSON(msg)
+			if err != nil {
+				return
+			}
+		case <-client.Done():
+			conn.Close()
+			return
+		case <-conn.Done():
+			return
+		}
+	}
+}
+
+func sessionID(hs *Session) (id string) {
+	if hs.Sessionable != nil {
+		id = hs.ID()
+	}
+	return
+}
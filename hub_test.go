@@ -0,0 +1,166 @@
+package web
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xmap"
+	"golang.org/x/net/websocket"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	hub.ShowLog = true
+	hub.QueueSize = 2
+	evicted := make(chan *HubClient, 1)
+	hub.OnEvict = func(client *HubClient) {
+		evicted <- client
+	}
+	c1 := hub.Join("s1", "u1", "t1", "t2")
+	c2 := hub.Join("s2", "u1", "t1")
+	c3 := hub.Join("s3", "", "t2")
+	if hub.Size() != 3 || len(hub.UserClients("u1")) != 2 || len(hub.SessionClients("s1")) != 1 || len(c1.Topics()) != 2 {
+		t.Errorf("size:%v", hub.Size())
+		return
+	}
+	//topic
+	hub.Publish("t1", "a", 1)
+	if msg := <-c1.Receive(); msg.Event != "a" || msg.Data != 1 {
+		t.Errorf("msg:%v", msg)
+		return
+	}
+	if msg := <-c2.Receive(); msg.Event != "a" {
+		t.Errorf("msg:%v", msg)
+		return
+	}
+	if len(c3.Receive()) > 0 {
+		t.Error("error")
+		return
+	}
+	//session and user
+	hub.SendSession("s3", "b", 2)
+	if msg := <-c3.Receive(); msg.Event != "b" {
+		t.Errorf("msg:%v", msg)
+		return
+	}
+	hub.SendUser("u1", "c", 3)
+	if len(c1.Receive()) != 1 || len(c2.Receive()) != 1 {
+		t.Error("error")
+		return
+	}
+	<-c1.Receive()
+	<-c2.Receive()
+	//unsubscribe
+	c1.Unsubscribe("t1")
+	hub.Publish("t1", "d", 4)
+	if len(c1.Receive()) > 0 || len(c2.Receive()) != 1 {
+		t.Error("error")
+		return
+	}
+	//evict
+	hub.Publish("t1", "e", 5)
+	hub.Publish("t1", "f", 6)
+	select {
+	case client := <-evicted:
+		if client != c2 || !c2.Evicted {
+			t.Error("error")
+			return
+		}
+	case <-time.After(time.Second):
+		t.Error("not evicted")
+		return
+	}
+	<-c2.Done()
+	if hub.Size() != 2 || len(hub.TopicClients("t1")) != 0 {
+		t.Errorf("size:%v", hub.Size())
+		return
+	}
+	//leave
+	c1.Close()
+	c3.Close()
+	c3.Close()
+	c3.Subscribe("t3")
+	if hub.Size() != 0 || len(hub.UserClients("u1")) != 0 || len(hub.TopicClients("t3")) != 0 {
+		t.Errorf("size:%v", hub.Size())
+		return
+	}
+}
+
+func TestHubServe(t *testing.T) {
+	hub := NewHub()
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/sse$", func(s *Session) Result {
+		hub.ServeSSE(s, "u1", "t1")
+		return Return
+	})
+	handler := mux.HandleWebSocket("^/ws$", func(s *Session, conn *WebSocketConn) {
+		hub.ServeWebSocket(s, conn, "u2", "t1")
+	})
+	handler.PingInterval = 0
+	ts := httptest.NewServer(mux)
+	waitSize := func(size int) bool {
+		for i := 0; i < 100; i++ {
+			if hub.Size() == size {
+				return true
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+	//sse
+	res, err := http.Get(ts.URL + "/sse")
+	if err != nil || !waitSize(1) {
+		t.Errorf("err:%v", err)
+		return
+	}
+	hub.Publish("t1", "a", xmap.M{"x": 1})
+	lines := readSSE(t, bufio.NewReader(res.Body), 2)
+	if strings.Join(lines, "\n") != "event: a\n"+`data: {"x":1}` {
+		t.Errorf("lines:%v", lines)
+		return
+	}
+	hub.UserClients("u1")[0].Close()
+	if !waitSize(0) {
+		t.Error("not leaved")
+		return
+	}
+	res.Body.Close()
+	//websocket
+	conn, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/ws", "", ts.URL)
+	if err != nil || !waitSize(1) {
+		t.Errorf("err:%v", err)
+		return
+	}
+	hub.SendUser("u2", "b", "abc")
+	msg := &HubMessage{}
+	if err = websocket.JSON.Receive(conn, msg); err != nil || msg.Event != "b" || msg.Data != "abc" {
+		t.Errorf("err:%v,msg:%v", err, msg)
+		return
+	}
+	conn.Close()
+	if !waitSize(0) {
+		t.Error("not leaved")
+		return
+	}
+	//evicted
+	conn, err = websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/ws", "", ts.URL)
+	if err != nil || !waitSize(1) {
+		t.Errorf("err:%v", err)
+		return
+	}
+	hub.Leave(hub.UserClients("u2")[0])
+	var text string
+	if err = websocket.Message.Receive(conn, &text); err == nil {
+		t.Error(err)
+		return
+	}
+	//sse error
+	if err = hub.ServeSSE(&Session{W: &noFlushWriter{header: http.Header{}}, R: httptest.NewRequest("GET", "/", nil)}, ""); err == nil {
+		t.Error(err)
+		return
+	}
+}
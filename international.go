@@ -1,82 +1,298 @@
 package web
 
-// import (
-// 	"encoding/json"
-// 	"io/ioutil"
-// 	"path/filepath"
-// 	"sync"
-// )
-
-// type JSONINT struct {
-// 	Path    string
-// 	Default string
-// 	Local   string
-// 	Kvs     map[string]map[string]string
-// 	lock    sync.RWMutex
-// }
-
-// func (j *JSONINT) SetLocal(hs *HTTPSession, local string) {
-// 	j.Local = local
-// }
-
-// func (j *JSONINT) LocalVal(hs *HTTPSession, key string) string {
-// 	if len(j.Local) > 0 {
-// 		val := j.LangVal(j.Local, key)
-// 		if len(val) > 0 {
-// 			return val
-// 		}
-// 	}
-// 	als := hs.AcceptLanguages()
-// 	if len(als) < 1 {
-// 		return j.LangVal(j.Default, key)
-// 	} else {
-// 		return j.LangQesVal(als, key)
-// 	}
-// }
-
-// func (j *JSONINT) LangQesVal(als LangQes, key string) string {
-// 	for _, al := range als {
-// 		val := j.LangVal(al.Lang, key)
-// 		if len(val) > 0 {
-// 			return val
-// 		}
-// 	}
-// 	return j.LangVal(j.Default, key)
-// }
-
-// func (j *JSONINT) LangVal(lang string, key string) string {
-// 	if _, ok := j.Kvs[lang]; !ok {
-// 		j.lock.Lock()
-// 		err := j.LoadJson(lang)
-// 		if err != nil { //load error or not found,marked loaded.
-// 			j.Kvs[lang] = map[string]string{}
-// 		}
-// 		j.lock.Unlock()
-// 	}
-// 	return j.Kvs[lang][key]
-// }
-
-// func (j *JSONINT) LoadJson(lang string) error {
-// 	fpath := filepath.Join(j.Path, lang+".json")
-// 	bys, err := ioutil.ReadFile(fpath)
-// 	if err != nil {
-// 		WarnLog("reading file(%v) error(%v)", fpath, err.Error())
-// 		return err
-// 	}
-// 	mv := map[string]string{}
-// 	err = json.Unmarshal(bys, &mv)
-// 	if err != nil {
-// 		WarnLog("load the lang(%s) json file(%s) error:%s", lang, fpath, err.Error())
-// 		return err
-// 	}
-// 	j.Kvs[lang] = mv
-// 	return nil
-// }
-
-// func NewJSONINT(path string) (*JSONINT, error) {
-// 	ji := &JSONINT{}
-// 	ji.Path = path
-// 	ji.Kvs = map[string]map[string]string{}
-// 	ji.lock = sync.RWMutex{}
-// 	return ji, nil
-// }
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/codingeasygo/util/converter"
+	"github.com/codingeasygo/util/xmap"
+)
+
+// International is the interface to resolve session locale and find local value
+type International interface {
+	//Locale will resolve the locale of session
+	Locale(s *Session) string
+	//SetLocale will store the locale of session
+	SetLocale(s *Session, locale string)
+	//LocalValue will return local value by locale and key, the key is returned when not found
+	LocalValue(locale, key string, args ...interface{}) string
+}
+
+var localeRegex = regexp.MustCompile(`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// CanonicalLocale will return canonical locale like zh-CN from zh_cn, empty is returned when it is invalid
+func CanonicalLocale(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	if !localeRegex.MatchString(locale) {
+		return ""
+	}
+	parts := strings.Split(locale, "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i, part := range parts[1:] {
+		switch len(part) {
+		case 2:
+			parts[i+1] = strings.ToUpper(part)
+		case 4:
+			parts[i+1] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// JSONINT is the International implement by json catalog file like en.json, zh-CN.json in directory or fs.FS,
+// the catalog value can be string or plural object like {"zero":"no item","one":"one item","other":"{count} items"},
+// the named argument is interpolated by {name}
+type JSONINT struct {
+	FS       fs.FS
+	Default  string            //the default locale, it is the last of fallback chain
+	Fallback map[string]string //the fallback locale, like zh-TW to zh-CN
+	Cookie   string            //the cookie name to override locale
+	Query    string            //the query key to override locale
+	Session  string            //the session key to override locale
+	catalogs map[string]map[string]interface{}
+	lock     sync.RWMutex
+}
+
+// NewJSONINT will create JSONINT by catalog directory
+func NewJSONINT(dir string) (*JSONINT, error) {
+	return NewFSJSONINT(os.DirFS(dir))
+}
+
+// NewFSJSONINT will create JSONINT by catalog fs.FS, all json file in root is loaded
+func NewFSJSONINT(fsys fs.FS) (j *JSONINT, err error) {
+	j = &JSONINT{
+		FS:       fsys,
+		Default:  "en",
+		Fallback: map[string]string{},
+		Cookie:   "lang",
+		Query:    "lang",
+		Session:  "lang",
+		catalogs: map[string]map[string]interface{}{},
+	}
+	err = j.Load()
+	return
+}
+
+// Load will load all json catalog from FS, the invalid catalog is skipped
+func (j *JSONINT) Load() (err error) {
+	files, err := fs.Glob(j.FS, "*.json")
+	if err != nil {
+		return
+	}
+	catalogs := map[string]map[string]interface{}{}
+	for _, file := range files {
+		locale := CanonicalLocale(strings.TrimSuffix(path.Base(file), ".json"))
+		if len(locale) < 1 {
+			continue
+		}
+		data, xerr := fs.ReadFile(j.FS, file)
+		if xerr != nil {
+			WarnLog("JSONINT read catalog file(%v) fail with %v", file, xerr)
+			continue
+		}
+		catalog := map[string]interface{}{}
+		if xerr = json.Unmarshal(data, &catalog); xerr != nil {
+			WarnLog("JSONINT load catalog file(%v) fail with %v", file, xerr)
+			continue
+		}
+		catalogs[locale] = catalog
+	}
+	j.lock.Lock()
+	j.catalogs = catalogs
+	j.lock.Unlock()
+	return
+}
+
+// Locales will return all loaded locale
+func (j *JSONINT) Locales() (locales []string) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	for locale := range j.catalogs {
+		locales = append(locales, locale)
+	}
+	return
+}
+
+// Supported will check if locale or its parent locale is loaded
+func (j *JSONINT) Supported(locale string) bool {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	locale = CanonicalLocale(locale)
+	for len(locale) > 0 {
+		if _, ok := j.catalogs[locale]; ok {
+			return true
+		}
+		locale = parentLocale(locale)
+	}
+	return false
+}
+
+func parentLocale(locale string) string {
+	if idx := strings.LastIndex(locale, "-"); idx > 0 {
+		return locale[:idx]
+	}
+	return ""
+}
+
+// Chain will return the fallback chain of locale, like zh-Hant-TW,zh-Hant,zh,<fallback>,<default>
+func (j *JSONINT) Chain(locale string) (chain []string) {
+	added := map[string]bool{}
+	add := func(locale string) {
+		for locale = CanonicalLocale(locale); len(locale) > 0; locale = parentLocale(locale) {
+			if !added[locale] {
+				added[locale] = true
+				chain = append(chain, locale)
+			}
+		}
+	}
+	add(locale)
+	for i := 0; i < len(chain); i++ {
+		if fallback, ok := j.Fallback[chain[i]]; ok {
+			add(fallback)
+		}
+	}
+	add(j.Default)
+	return
+}
+
+// LocalValue will return local value by fallback chain of locale, the plural form is selected by count argument,
+// the args can be one map or key/value pairs like "name", "abc", "count", 2
+func (j *JSONINT) LocalValue(locale, key string, args ...interface{}) string {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	for _, locale := range j.Chain(locale) {
+		if val, ok := j.catalogs[locale][key]; ok {
+			return formatLocal(val, args...)
+		}
+	}
+	return key
+}
+
+// Locale will resolve session locale by query, cookie, session value and Accept-Language in order, the default is returned when not found
+func (j *JSONINT) Locale(s *Session) string {
+	if len(j.Query) > 0 && s.R.URL != nil {
+		if locale := CanonicalLocale(s.R.URL.Query().Get(j.Query)); len(locale) > 0 && j.Supported(locale) {
+			return locale
+		}
+	}
+	if len(j.Cookie) > 0 {
+		if locale := CanonicalLocale(s.Cookie(j.Cookie)); len(locale) > 0 && j.Supported(locale) {
+			return locale
+		}
+	}
+	if len(j.Session) > 0 && s.Sessionable != nil {
+		if locale := CanonicalLocale(s.Str(j.Session)); len(locale) > 0 && j.Supported(locale) {
+			return locale
+		}
+	}
+	for _, locale := range s.AcceptLanguages() {
+		if j.Supported(locale) {
+			return locale
+		}
+	}
+	return j.Default
+}
+
+// SetLocale will store locale to session value when Session key is set, and store it to cookie when Cookie is set,
+// the cookie is deleted when locale is empty, so the stale cookie is not override the new locale
+func (j *JSONINT) SetLocale(s *Session, locale string) {
+	if len(j.Session) > 0 && s.Sessionable != nil {
+		s.SetValue(j.Session, locale)
+	}
+	if len(j.Cookie) > 0 && s.W != nil {
+		if len(locale) > 0 {
+			s.SetCookie(j.Cookie, locale)
+		} else {
+			s.DeleteCookie(j.Cookie)
+		}
+	}
+}
+
+var localArgRegex = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// formatLocal will select plural form by count argument and interpolate named argument
+func formatLocal(val interface{}, args ...interface{}) string {
+	vars := localArgs(args...)
+	var text string
+	switch v := val.(type) {
+	case string:
+		text = v
+	case map[string]interface{}:
+		form := "other"
+		if count, err := converter.Int64Val(vars["count"]); err == nil {
+			if _, ok := v["zero"]; ok && count == 0 {
+				form = "zero"
+			} else if _, ok := v["one"]; ok && count == 1 {
+				form = "one"
+			}
+		}
+		text, _ = v[form].(string)
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+	if len(vars) < 1 {
+		return text
+	}
+	return localArgRegex.ReplaceAllStringFunc(text, func(name string) string {
+		if arg, ok := vars[name[1:len(name)-1]]; ok {
+			return fmt.Sprintf("%v", arg)
+		}
+		return name
+	})
+}
+
+func localArgs(args ...interface{}) (vars map[string]interface{}) {
+	if len(args) == 1 {
+		switch v := args[0].(type) {
+		case map[string]interface{}:
+			return v
+		case xmap.M:
+			return v
+		}
+	}
+	vars = map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		vars[fmt.Sprintf("%v", args[i])] = args[i+1]
+	}
+	return
+}
+
+// AcceptLanguages will return the locale in Accept-Language which is sorted by q-value
+func (s *Session) AcceptLanguages() (locales []string) {
+	for _, value := range parseAccept(s.R.Header.Get("Accept-Language")) {
+		if locale := CanonicalLocale(value.Type); value.Q > 0 && len(locale) > 0 {
+			locales = append(locales, locale)
+		}
+	}
+	return
+}
+
+// Locale will return session locale, it is set by SetLocale or resolved by mux INT
+func (s *Session) Locale() string {
+	if len(s.locale) < 1 && s.Mux != nil && s.Mux.INT != nil {
+		s.locale = s.Mux.INT.Locale(s)
+	}
+	return s.locale
+}
+
+// SetLocale will set session locale and store it by mux INT
+func (s *Session) SetLocale(locale string) {
+	s.locale = CanonicalLocale(locale)
+	if s.Mux != nil && s.Mux.INT != nil {
+		s.Mux.INT.SetLocale(s, s.locale)
+	}
+}
+
+// LocalValue will return local value by session locale, the key is returned when mux INT is not set or key is not found
+func (s *Session) LocalValue(key string, args ...interface{}) string {
+	if s.Mux == nil || s.Mux.INT == nil {
+		return key
+	}
+	return s.Mux.INT.LocalValue(s.Locale(), key, args...)
+}
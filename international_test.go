@@ -0,0 +1,229 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestJSONINT(t *testing.T) {
+	inter, err := NewJSONINT(".")
+	if err != nil || len(inter.Locales()) != 2 || !inter.Supported("zh-CN") || inter.Supported("abc") || inter.Supported("fr") {
+		t.Errorf("err:%v,locales:%v", err, inter.Locales())
+		return
+	}
+	builder := NewMemSessionBuilder("", "/", "itest", time.Minute)
+	mux := NewBuilderSessionMux("", builder)
+	mux.INT = inter
+	mux.HandleFunc("^/abc$", func(s *Session) Result {
+		return s.Printf("%v:%v", s.Locale(), s.LocalValue("abc"))
+	})
+	mux.HandleFunc("^/set$", func(s *Session) Result {
+		s.SetLocale(s.R.URL.Query().Get("locale"))
+		return s.Printf("%v", s.Locale())
+	})
+	ts := httptest.NewServer(mux)
+	get := func(header xmap.M, format string, args ...interface{}) string {
+		text, _, _ := xhttp.GetHeaderText(header, format, args...)
+		return text
+	}
+	if text := get(nil, "%v/abc", ts.URL); text != "en:this is en value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text := get(xmap.M{"Accept-Language": "fr;q=0.9, zh-cn;q=0.8, en;q=0.5"}, "%v/abc", ts.URL); text != "zh-CN:this is zh value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text := get(xmap.M{"Accept-Language": "zh;q=0, fr"}, "%v/abc", ts.URL); text != "en:this is en value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text := get(xmap.M{"Accept-Language": "zh"}, "%v/abc?lang=en", ts.URL); text != "en:this is en value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text := get(xmap.M{"Cookie": "lang=zh"}, "%v/abc?lang=../x", ts.URL); text != "zh:this is zh value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//session value
+	if text := get(nil, "%v/set?locale=zh", ts.URL); text != "zh" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text := get(nil, "%v/abc", ts.URL); text != "zh:this is zh value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	get(nil, "%v/set?locale=", ts.URL)
+	if text := get(nil, "%v/abc", ts.URL); text != "en:this is en value" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//stale cookie
+	for locale, cookie := range map[string]string{"zh": "lang=zh", "": "lang=; Path=/; Expires="} {
+		w := serveCookie(mux, "/set?locale="+locale, "lang=en")
+		if !strings.Contains(strings.Join(w.Header().Values("Set-Cookie"), "\n"), cookie) {
+			t.Errorf("cookies:%v", w.Header().Values("Set-Cookie"))
+			return
+		}
+	}
+	//no international
+	session := &Session{R: httptest.NewRequest("GET", "/", nil)}
+	session.SetLocale("zh")
+	if session.LocalValue("abc") != "abc" || session.Locale() != "zh" {
+		t.Error("error")
+		return
+	}
+}
+
+func TestJSONINTFormat(t *testing.T) {
+	inter, err := NewFSJSONINT(fstest.MapFS{
+		"en.json":         {Data: []byte(`{"items":{"zero":"no item","one":"one item","other":"{count} items"},"hello":"hello {name}, {unknown}","color":"color","num":1}`)},
+		"en-GB.json":      {Data: []byte(`{"color":"colour"}`)},
+		"zh-Hant-TW.json": {Data: []byte(`{"hello":"你好 {name}"}`)},
+		"zh-CN.json":      {Data: []byte(`{"items":{"other":"{count} 个"},"color":"颜色"}`)},
+		"dir/x.json":      {Data: []byte(`{}`)},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	inter.Fallback["zh-Hant-TW"] = "zh-CN"
+	if chain := inter.Chain("zh_hant_tw"); len(chain) != 5 || chain[3] != "zh-CN" || chain[4] != "en" {
+		t.Errorf("chain:%v", chain)
+		return
+	}
+	for _, c := range []struct {
+		Locale string
+		Key    string
+		Args   []interface{}
+		Expect string
+	}{
+		{"en", "items", []interface{}{"count", 0}, "no item"},
+		{"en", "items", []interface{}{"count", 1}, "one item"},
+		{"en", "items", []interface{}{xmap.M{"count": 3}}, "3 items"},
+		{"en", "items", nil, "{count} items"},
+		{"zh-CN", "items", []interface{}{map[string]interface{}{"count": 1}}, "1 个"},
+		{"en-GB", "color", nil, "colour"},
+		{"en-US", "color", nil, "color"},
+		{"zh-Hant-TW", "color", nil, "颜色"},
+		{"zh-Hant-TW", "hello", []interface{}{"name", "x"}, "你好 x"},
+		{"fr", "hello", []interface{}{"name", "x"}, "hello x, {unknown}"},
+		{"fr", "num", nil, "1"},
+		{"fr", "none", nil, "none"},
+	} {
+		if val := inter.LocalValue(c.Locale, c.Key, c.Args...); val != c.Expect {
+			t.Errorf("%v,%v,%v->%v", c.Locale, c.Key, c.Args, val)
+			return
+		}
+	}
+	if CanonicalLocale("EN_us") != "en-US" || CanonicalLocale("../x") != "" || CanonicalLocale("*") != "" {
+		t.Error("error")
+		return
+	}
+}
+
+func TestLocalizedErrors(t *testing.T) {
+	inter, _ := NewFSJSONINT(fstest.MapFS{
+		"en.json": {Data: []byte(`{"valid.required":"{field} is required","valid.name.length":"name must be {min}-{max} chars","error.not_found":"nothing here","error.too_many_requests":"slow down","error.arg_invalid":"bad arg","error.validation":"check input"}`)},
+		"zh.json": {Data: []byte(`{"valid.required":"{field} 必填","error.not_found":"找不到","error.arg_invalid":"参数错误","error.method_not_allowed":"方法不允许"}`)},
+	})
+	mux := NewSessionMux("")
+	mux.INT = inter
+	mux.HandleErrorFunc("^/valid$", func(s *Session) error {
+		var name string
+		var age int64
+		return s.ValidFormat(`name,R|S,L:2~8;age,R|I,R:0~150`, &name, &age)
+	})
+	mux.HandleMethodFunc("^/get$", func(s *Session) Result {
+		return s.Printf("ok")
+	}, "GET,HEAD")
+	mux.HandleFunc("^/code$", func(s *Session) Result {
+		return s.SendError(CodeArgInvalid, nil)
+	})
+	mux.HandleMethodFunc("^/both$", func(s *Session) Result {
+		return s.Printf("get")
+	}, "GET")
+	mux.HandleFunc("^/both$", func(s *Session) Result {
+		return s.Printf("other")
+	})
+	static := NewStaticDir("test")
+	static.Prefix = "/static"
+	mux.Handle("^/static/.*$", static)
+	mux.HandleFunc("^/limit$", func(s *Session) Result {
+		return s.SendStatusPage(http.StatusTooManyRequests)
+	})
+	mux.HandleErrorFunc("^/large$", func(s *Session) error {
+		_, err := io.ReadAll(http.MaxBytesReader(s.W, s.R.Body, 4))
+		return err
+	})
+	ts := httptest.NewServer(mux)
+	//validation
+	text, _, _ := xhttp.GetHeaderText(nil, "%v/valid?name=a", ts.URL)
+	res, _ := xmap.MapVal(text)
+	if res.Str("detail") != "check input" || res.StrDef("", "errors/0/message") != "name must be 2-8 chars" || res.StrDef("", "errors/1/message") != "age is required" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, _, _ = xhttp.GetHeaderText(xmap.M{"Accept-Language": "zh"}, "%v/valid?name=abc", ts.URL)
+	res, _ = xmap.MapVal(text)
+	if res.StrDef("", "errors/0/message") != "age 必填" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//code
+	res, _, _ = xhttp.GetHeaderMap(xmap.M{"Accept-Language": "zh"}, "%v/code", ts.URL)
+	if res.Str("msg") != "参数错误" {
+		t.Errorf("res:%v", res)
+		return
+	}
+	//status page
+	text, resp, _ := xhttp.GetHeaderText(xmap.M{"Accept-Language": "zh"}, "%v/none", ts.URL)
+	if resp.StatusCode != http.StatusNotFound || strings.TrimSpace(text) != "找不到" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, resp, _ = xhttp.PostHeaderText(xmap.M{"Accept-Language": "zh"}, nil, "%v/get", ts.URL)
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" || strings.TrimSpace(text) != "方法不允许" {
+		t.Errorf("text:%v,header:%v", text, resp.Header)
+		return
+	}
+	text, resp, _ = xhttp.PostHeaderText(nil, nil, "%v/get", ts.URL)
+	if resp.StatusCode != http.StatusMethodNotAllowed || strings.TrimSpace(text) != http.StatusText(http.StatusMethodNotAllowed) {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if text, _, _ = xhttp.PostHeaderText(nil, nil, "%v/both", ts.URL); text != "other" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, resp, _ = xhttp.GetHeaderText(xmap.M{"Accept-Language": "zh"}, "%v/static/none.html", ts.URL)
+	if resp.StatusCode != http.StatusNotFound || strings.TrimSpace(text) != "找不到" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, resp, _ = xhttp.PostHeaderText(xmap.M{"Accept-Language": "zh"}, nil, "%v/static/test.html", ts.URL)
+	if resp.StatusCode != http.StatusMethodNotAllowed || strings.TrimSpace(text) != "方法不允许" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, resp, _ = xhttp.GetHeaderText(nil, "%v/limit", ts.URL)
+	if resp.StatusCode != http.StatusTooManyRequests || strings.TrimSpace(text) != "slow down" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, resp, _ = xhttp.PostHeaderText(nil, strings.NewReader("123456"), "%v/large", ts.URL)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("text:%v", text)
+		return
+	}
+}
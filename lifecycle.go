@@ -0,0 +1,69 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrSessionUnsupported is the error of session builder or session is not supported the operation
+var ErrSessionUnsupported = errors.New("session operation not supported")
+
+// ErrSessionLimit is the error of user session count is reached the limit
+var ErrSessionLimit = errors.New("session limit reached")
+
+// SessionLifecycle is the interface of session builder to manage session lifecycle
+type SessionLifecycle interface {
+	//Regenerate will move session values to new session id and return the new session, the cookie is written to w when it is not nil
+	Regenerate(w http.ResponseWriter, s Sessionable) (session Sessionable, err error)
+	//Destroy will remove session, the cookie is deleted by w when it is not nil
+	Destroy(w http.ResponseWriter, s Sessionable) (err error)
+	//BindUser will bind session to user, ErrSessionLimit is returned when user session limit is reached and rejected
+	BindUser(s Sessionable, user string) (err error)
+	//FindByUser will return all session which is bound to user
+	FindByUser(user string) (sessions []Sessionable)
+	//DestroyUser will destroy all session which is bound to user
+	DestroyUser(user string) (count int, err error)
+}
+
+func (s *Session) lifecycle() (lifecycle SessionLifecycle, err error) {
+	if s.Mux == nil || s.Sessionable == nil {
+		err = ErrSessionUnsupported
+		return
+	}
+	lifecycle, ok := s.Mux.Builder.(SessionLifecycle)
+	if !ok {
+		err = ErrSessionUnsupported
+	}
+	return
+}
+
+// Regenerate will rotate session id by mux builder, it should be called after login to prevent session fixation
+func (s *Session) Regenerate() (err error) {
+	lifecycle, err := s.lifecycle()
+	if err != nil {
+		return
+	}
+	session, err := lifecycle.Regenerate(s.W, s.Sessionable)
+	if err == nil {
+		s.Sessionable = session
+	}
+	return
+}
+
+// Destroy will destroy session by mux builder, the session values is not saved after destroyed
+func (s *Session) Destroy() (err error) {
+	lifecycle, err := s.lifecycle()
+	if err == nil {
+		err = lifecycle.Destroy(s.W, s.Sessionable)
+	}
+	return
+}
+
+// BindUser will bind session to user by mux builder
+func (s *Session) BindUser(user string) (err error) {
+	lifecycle, err := s.lifecycle()
+	if err == nil {
+		err = lifecycle.BindUser(s.Sessionable, user)
+	}
+	return
+}
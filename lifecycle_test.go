@@ -0,0 +1,197 @@
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLifecycleMux(builder SessionBuilder) (mux *SessionMux) {
+	mux = NewBuilderSessionMux("", builder)
+	mux.HandleFunc("^/login$", func(s *Session) Result {
+		old := s.ID()
+		if err := s.BindUser(s.R.URL.Query().Get("user")); err != nil {
+			return s.Printf("%v", err)
+		}
+		if err := s.Regenerate(); err != nil {
+			return s.Printf("%v", err)
+		}
+		s.SetValue("name", "abc")
+		return s.Printf("%v,%v", old, s.ID())
+	})
+	mux.HandleFunc("^/logout$", func(s *Session) Result {
+		if err := s.Destroy(); err != nil {
+			return s.Printf("%v", err)
+		}
+		s.SetValue("name", "xyz")
+		return s.Printf("%v", s.ID())
+	})
+	mux.HandleFunc("^/get$", func(s *Session) Result {
+		return s.Printf("%v,%v", s.ID(), s.Str("name"))
+	})
+	return
+}
+
+func TestMemSessionLifecycle(t *testing.T) {
+	store := NewMemSessionStore()
+	builder := NewMemSessionBuilder("", "/", "ltest", time.Minute)
+	builder.Store = store
+	events := []string{}
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		events = append(events, key)
+	}))
+	mux := newLifecycleMux(builder)
+	//regenerate
+	w := serveCookie(mux, "/login?user=u1", "")
+	text, cookie := w.Body.String(), responseCookie(w, "ltest")
+	ids := strings.Split(text, ",")
+	if len(ids) != 2 || ids[0] == ids[1] || cookie == nil || cookie.Value != ids[1] {
+		t.Errorf("text:%v,cookie:%v", text, cookie)
+		return
+	}
+	if builder.Find(ids[0]) != nil || builder.Find(ids[1]) == nil || strings.Join(events, ",") != "CREATE,REGENERATE" {
+		t.Errorf("events:%v", events)
+		return
+	}
+	if _, _, err := store.Load(ids[0]); err != ErrSessionNotFound {
+		t.Error(err)
+		return
+	}
+	if text = serveCookie(mux, "/get", "ltest="+ids[1]).Body.String(); text != ids[1]+",abc" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//find by user
+	text = serveCookie(mux, "/login?user=u1", "").Body.String()
+	other := strings.Split(text, ",")[1]
+	serveCookie(mux, "/login?user=u2", "")
+	if sessions := builder.FindByUser("u1"); len(sessions) != 2 {
+		t.Errorf("sessions:%v", sessions)
+		return
+	}
+	//restart
+	builder = NewMemSessionBuilder("", "/", "ltest", time.Minute)
+	builder.Store = store
+	mux = newLifecycleMux(builder)
+	if sessions := builder.FindByUser("u1"); len(sessions) != 2 || sessions[0].Str("_user_") != "u1" || builder.Count() != 2 {
+		t.Errorf("sessions:%v,count:%v", sessions, builder.Count())
+		return
+	}
+	//destroy
+	w = serveCookie(mux, "/logout", "ltest="+ids[1])
+	text, cookie = w.Body.String(), responseCookie(w, "ltest")
+	if text != ids[1] || cookie == nil || cookie.MaxAge >= 0 {
+		t.Errorf("text:%v,cookie:%v", text, cookie)
+		return
+	}
+	if _, _, err := store.Load(ids[1]); err != ErrSessionNotFound || builder.Find(ids[1]) != nil || len(builder.FindByUser("u1")) != 1 {
+		t.Error(err)
+		return
+	}
+	//destroy user
+	events = []string{}
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		events = append(events, key)
+	}))
+	if count, err := builder.DestroyUser("u1"); err != nil || count != 1 || builder.Find(other) != nil || len(events) != 1 || events[0] != "DESTROY" {
+		t.Errorf("err:%v,count:%v,events:%v", err, count, events)
+		return
+	}
+	if count, _ := builder.DestroyUser("u1"); count != 0 || len(builder.FindByUser("u2")) != 1 {
+		t.Errorf("count:%v", count)
+		return
+	}
+	//destroy user in store
+	text = serveCookie(mux, "/login?user=u3", "").Body.String()
+	stored := strings.Split(text, ",")[1]
+	builder = NewMemSessionBuilder("", "/", "ltest", time.Minute)
+	builder.Store = store
+	mux = newLifecycleMux(builder)
+	if count, err := builder.DestroyUser("u3"); err != nil || count != 1 || builder.Count() != 0 {
+		t.Errorf("err:%v,count:%v", err, count)
+		return
+	}
+	if _, _, err := store.Load(stored); err != ErrSessionNotFound {
+		t.Error(err)
+		return
+	}
+	//error
+	if _, err := builder.Regenerate(nil, &DefaultSession{}); err != ErrSessionUnsupported {
+		t.Error(err)
+		return
+	}
+	if err := builder.Destroy(nil, &MemSession{token: "none"}); err != ErrSessionNotFound {
+		t.Error(err)
+		return
+	}
+	if err := builder.BindUser(&MemSession{token: "none"}, "u1"); err != ErrSessionNotFound {
+		t.Error(err)
+		return
+	}
+	mux = newLifecycleMux(NewDefaultSessionBuilder())
+	if text = serveCookie(mux, "/login?user=u1", "").Body.String(); text != ErrSessionUnsupported.Error() {
+		t.Errorf("text:%v", text)
+		return
+	}
+	session := &Session{}
+	if session.Regenerate() != ErrSessionUnsupported || session.Destroy() != ErrSessionUnsupported {
+		t.Error("error")
+		return
+	}
+}
+
+func TestCookieSessionLifecycle(t *testing.T) {
+	builder, _ := NewCookieSessionBuilder("", "/", "ltest", time.Minute, []byte("key"))
+	events := []string{}
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		events = append(events, key)
+	}))
+	mux := newLifecycleMux(builder)
+	w := serveCookie(mux, "/login?user=u1", "")
+	text, cookie := w.Body.String(), responseCookie(w, "ltest")
+	ids := strings.Split(text, ",")
+	if len(ids) != 2 || ids[0] == ids[1] || cookie == nil || strings.Join(events, ",") != "CREATE,REGENERATE" {
+		t.Errorf("text:%v,cookie:%v,events:%v", text, cookie, events)
+		return
+	}
+	if text = serveCookie(mux, "/get", "ltest="+cookie.Value).Body.String(); text != ids[1]+",abc" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	w = serveCookie(mux, "/logout", "ltest="+cookie.Value)
+	text, deleted := w.Body.String(), responseCookie(w, "ltest")
+	if text != ids[1] || deleted == nil || deleted.MaxAge >= 0 || events[len(events)-1] != "DESTROY" {
+		t.Errorf("text:%v,cookie:%v", text, deleted)
+		return
+	}
+	if _, err := builder.DestroyUser("u1"); err != ErrSessionUnsupported || builder.FindByUser("u1") != nil {
+		t.Error(err)
+		return
+	}
+	//error
+	w = httptest.NewRecorder()
+	session := builder.FindSession(w, httptest.NewRequest("GET", "/", nil))
+	if err := builder.Destroy(w, session); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := builder.Regenerate(w, session); err != ErrSessionNotFound {
+		t.Error(err)
+		return
+	}
+	session = builder.FindSession(w, httptest.NewRequest("GET", "/", nil))
+	session.(responseWrapper).wrapResponse(w).WriteHeader(200)
+	if _, err := builder.Regenerate(w, session); err != ErrCookieHeaderSent {
+		t.Error(err)
+		return
+	}
+	if err := builder.Destroy(w, session); err != ErrCookieHeaderSent {
+		t.Error(err)
+		return
+	}
+	if _, err := builder.Regenerate(w, &DefaultSession{}); err != ErrSessionUnsupported || builder.Destroy(w, &DefaultSession{}) != ErrSessionUnsupported || builder.BindUser(&DefaultSession{}, "") != ErrSessionUnsupported {
+		t.Error(err)
+		return
+	}
+}
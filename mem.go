@@ -1,19 +1,46 @@
 package web
 
 import (
+	"container/heap"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/codingeasygo/util/uuid"
 	"github.com/codingeasygo/util/xmap"
 )
 
+// Clock is the interface to provide current time, it can be replaced to test session expiry
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is Clock implement by func
+type ClockFunc func() time.Time
+
+// Now will return current time
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
 // MemSession is memory session implement
 type MemSession struct {
 	xmap.Valuable
-	token  string
-	latest time.Time
+	token     string
+	latest    int64 //the latest access time in nano
+	cookie    int64 //the time of cookie sent in nano
+	touched   int64 //the time of store touched in nano
+	destroyed int32
+	pending   int32               //the lazy session is not created
+	dirty     int32               //the values is changed by SetValue, Delete or Clear
+	writer    http.ResponseWriter //the writer to send cookie of lazy session
+	builder   *MemSessionBuilder
+	user      string //the bound user, it is protected by shard locker
+	index     int    //the index in expiry heap, it is protected by shard locker
+	expiry    int64  //the latest time in expiry heap, it is protected by shard locker
 }
 
 // ID return the session id
@@ -21,26 +48,198 @@ func (m *MemSession) ID() string {
 	return m.token
 }
 
-// Flush will flush session latest time
-func (m *MemSession) Flush() error {
-	m.latest = time.Now()
-	return nil
+// Latest will return the session latest access time
+func (m *MemSession) Latest() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&m.latest))
+}
+
+// SetValue will set session value and mark session dirty, the lazy session is created on first setting,
+// ErrCookieHeaderSent is returned when the lazy session is set after response header is sent
+func (m *MemSession) SetValue(path string, val interface{}) (err error) {
+	if err = m.create(); err != nil {
+		return
+	}
+	if err = m.Valuable.SetValue(path, val); err == nil {
+		atomic.StoreInt32(&m.dirty, 1)
+	}
+	return
+}
+
+// Delete will delete session value and mark session dirty
+func (m *MemSession) Delete(path string) (err error) {
+	if err = m.Valuable.Delete(path); err == nil {
+		atomic.StoreInt32(&m.dirty, 1)
+	}
+	return
+}
+
+// Clear will clear session value and mark session dirty
+func (m *MemSession) Clear() (err error) {
+	if err = m.Valuable.Clear(); err == nil {
+		atomic.StoreInt32(&m.dirty, 1)
+	}
+	return
+}
+
+// create will create lazy session on builder, it is refused when response header is sent, because the cookie can't be sent
+func (m *MemSession) create() (err error) {
+	if atomic.LoadInt32(&m.pending) == 0 {
+		return
+	}
+	if writer, ok := m.writer.(*memSessionWriter); ok && writer.isSent() {
+		err = ErrCookieHeaderSent
+		return
+	}
+	if atomic.CompareAndSwapInt32(&m.pending, 1, 0) {
+		m.builder.create(m, m.writer)
+		m.writer = nil
+	}
+	return
+}
+
+func (m *MemSession) wrapResponse(w http.ResponseWriter) http.ResponseWriter {
+	if atomic.LoadInt32(&m.pending) == 0 {
+		return w
+	}
+	writer := &memSessionWriter{ResponseWriter: w}
+	m.writer = writer
+	return writer
+}
+
+// memSessionWriter will record response header is sent, it is used to refuse creating lazy session after header is sent
+type memSessionWriter struct {
+	http.ResponseWriter
+	sent int32
+}
+
+func (m *memSessionWriter) WriteHeader(code int) {
+	atomic.StoreInt32(&m.sent, 1)
+	m.ResponseWriter.WriteHeader(code)
+}
+
+func (m *memSessionWriter) Write(p []byte) (n int, err error) {
+	atomic.StoreInt32(&m.sent, 1)
+	n, err = m.ResponseWriter.Write(p)
+	return
+}
+
+func (m *memSessionWriter) Unwrap() http.ResponseWriter {
+	return m.ResponseWriter
+}
+
+func (m *memSessionWriter) isSent() bool {
+	return atomic.LoadInt32(&m.sent) == 1
+}
+
+// touch will update session latest time, the store is touched once per tenth of builder Timeout to reduce store writing
+func (m *MemSession) touch() {
+	if m.isDestroyed() {
+		return
+	}
+	now := m.builder.now()
+	atomic.StoreInt64(&m.latest, now.UnixNano())
+	touched := atomic.LoadInt64(&m.touched)
+	if m.builder.Store == nil || now.UnixNano()-touched < int64(m.builder.Timeout/10) || !atomic.CompareAndSwapInt64(&m.touched, touched, now.UnixNano()) {
+		return
+	}
+	if err := m.builder.Store.Touch(m.token, now); err != nil && err != ErrSessionNotFound {
+		WarnLog("MemSession touch session %v fail with %v", m.token, err)
+	}
+}
+
+// Flush will flush session latest time and save values to store if session is dirty,
+// the destroyed or lazy session is skipped and it is no-op when builder store is not set
+func (m *MemSession) Flush() (err error) {
+	if m.isDestroyed() || atomic.LoadInt32(&m.pending) == 1 || m.builder.Store == nil {
+		return
+	}
+	now := m.builder.now()
+	atomic.StoreInt64(&m.latest, now.UnixNano())
+	if atomic.CompareAndSwapInt32(&m.dirty, 1, 0) {
+		if err = m.builder.Store.Save(m.token, m.values(), now); err != nil {
+			atomic.StoreInt32(&m.dirty, 1)
+		} else {
+			atomic.StoreInt64(&m.touched, now.UnixNano())
+		}
+	}
+	return
+}
+
+// IsDirty is implement for DirtySession, it is true when the values is changed and not saved to builder store
+func (m *MemSession) IsDirty() bool {
+	return m.builder != nil && m.builder.Store != nil && !m.isDestroyed() && atomic.LoadInt32(&m.pending) == 0 && atomic.LoadInt32(&m.dirty) == 1
+}
+
+func (m *MemSession) isDestroyed() bool {
+	return atomic.LoadInt32(&m.destroyed) == 1
+}
+
+// values will return the copy of session values
+func (m *MemSession) values() (values map[string]interface{}) {
+	return copyValues(m.Valuable)
+}
+
+// replaceValues will replace all valuable map values by values, the SafeM is locked when replacing
+func replaceValues(v xmap.Valuable, values map[string]interface{}) {
+	if locker, ok := v.(interface {
+		Lock()
+		Unlock()
+	}); ok {
+		locker.Lock()
+		defer locker.Unlock()
+	}
+	if raw, ok := v.Raw().(xmap.M); ok {
+		for k := range raw {
+			delete(raw, k)
+		}
+		for k, v := range values {
+			raw[k] = v
+		}
+	}
+}
+
+// copyValues will return the copy of valuable map, the SafeM is read locked when copying
+func copyValues(v xmap.Valuable) (values map[string]interface{}) {
+	if locker, ok := v.(interface {
+		RLock()
+		RUnlock()
+	}); ok {
+		locker.RLock()
+		defer locker.RUnlock()
+	}
+	values = map[string]interface{}{}
+	if raw, ok := v.Raw().(xmap.M); ok {
+		for k, v := range raw {
+			values[k] = v
+		}
+	}
+	return
 }
 
-// MemSessionBuilder is memory session builder implement
+// MemSessionBuilder is memory session builder implement, the sessions is sharded by id and expired by min-heap of latest access time
 type MemSessionBuilder struct {
 	xmap.Valuable
 	Domain    string
 	Path      string
 	Timeout   time.Duration
-	CookieKey string //cookie key
+	CookieKey string       //cookie key
+	UserKey   string       //the session value key to store bound user, default is _user_
+	Cookie    CookieOption //the session cookie option, see CookieOption.WithDefault
+	Store     SessionStore //the store to persist session, the session is only kept in memory when it is nil
+	Clock     Clock        //the clock to provide current time, default is time.Now
+	Lazy      bool         //create session and send cookie only when value is set, it is used to skip anonymous traffic
 	ShowLog   bool
 	Event     SessionEventHandler
 	//
-	delay    time.Duration
-	looping  bool
-	sessions map[string]*MemSession //key session
-	locker   sync.RWMutex
+	delay      time.Duration    //the delay of expire loop
+	scanDelay  time.Duration    //the delay of scanning store in expire loop
+	maxSession int64            //the max session count, zero is not limited
+	maxUser    int64            //the max session count of user, zero is not limited
+	rejectUser int32            //reject new bound when user session count is reached limit
+	memory     *MemSessionStore //the memory storage of session
+	stopper    chan struct{}
+	waiter     sync.WaitGroup
+	loopLocker sync.Mutex
 }
 
 // NewMemSessionBuilder will return new MemSessionBuilder
@@ -50,12 +249,14 @@ func NewMemSessionBuilder(domain string, path string, cookie string, timeout tim
 	sb.Path = path
 	sb.Timeout = timeout
 	sb.delay = time.Second
+	sb.scanDelay = time.Minute
 	sb.CookieKey = cookie
-	sb.Valuable = xmap.New()
+	sb.Cookie = CookieOption{HttpOnly: true, MaxAge: int(timeout / time.Second)}
+	sb.Clock = ClockFunc(time.Now)
 	sb.ShowLog = false
 	sb.Valuable = xmap.New()
-	sb.sessions = map[string]*MemSession{}
-	sb.locker = sync.RWMutex{}
+	sb.UserKey = "_user_"
+	sb.memory = NewMemSessionStore()
 	return &sb
 }
 func (m *MemSessionBuilder) log(f string, args ...interface{}) {
@@ -64,104 +265,660 @@ func (m *MemSessionBuilder) log(f string, args ...interface{}) {
 	}
 }
 
-// Find will find sesion by tokken
+func (m *MemSessionBuilder) now() time.Time {
+	if m.Clock == nil {
+		return time.Now()
+	}
+	return m.Clock.Now()
+}
+
+// SetMaxSessions will set the max session count in memory, the least recently used session is evicted when it is reached,
+// zero is not limited
+func (m *MemSessionBuilder) SetMaxSessions(max int) {
+	atomic.StoreInt64(&m.maxSession, int64(max))
+	m.limit(nil)
+}
+
+// SetMaxUserSessions will set the max concurrent session count of bound user, the oldest session of user is evicted
+// or new binding is rejected by ErrSessionLimit when it is reached, zero is not limited
+func (m *MemSessionBuilder) SetMaxUserSessions(max int, reject bool) {
+	atomic.StoreInt64(&m.maxUser, int64(max))
+	if reject {
+		atomic.StoreInt32(&m.rejectUser, 1)
+	} else {
+		atomic.StoreInt32(&m.rejectUser, 0)
+	}
+}
+
+// Count will return the session count in memory
+func (m *MemSessionBuilder) Count() int {
+	return m.memory.Count()
+}
+
+// Find will find sesion by tokken, the session is loaded from store when it is not in memory
 func (m *MemSessionBuilder) Find(id string) (session Sessionable) {
-	m.locker.RLock()
-	defer m.locker.RUnlock()
-	if v, ok := m.sessions[id]; ok {
+	if v := m.load(id); v != nil {
 		session = v
 	}
 	return
 }
 
-// FindSession will find the session by request
-func (m *MemSessionBuilder) FindSession(w http.ResponseWriter, r *http.Request) Sessionable {
-	c, err := r.Cookie(m.CookieKey)
-	ncookie := func() {
-		c = &http.Cookie{}
-		c.Name = m.CookieKey
-		c.Value = uuid.New()
-		c.Path = m.Path
-		c.Domain = m.Domain
-		c.MaxAge = 10 * 24 * 60 * 60
-		//
-		session := &MemSession{}
-		session.token = c.Value
-		session.Valuable = xmap.NewSafe()
-		session.Flush()
-		//
-		// s.ks_lck.Lock()
-		m.sessions[c.Value] = session
-		// s.ks_lck.Unlock()
-		http.SetCookie(w, c)
-		if m.Event != nil {
-			m.Event.OnCreate(session)
-		}
-		// s.log("setting cookie %v=%v to %v", c.Name, c.Value, r.Host)
-	}
-	m.locker.Lock()
-	defer m.locker.Unlock()
-	var ss Sessionable
-	if w != nil {
-		if err != nil {
-			ncookie()
+// load will find session in memory or load it from store, the store is loaded without shard locked,
+// so the session is loaded again when any session of shard is deleted from store in loading
+func (m *MemSessionBuilder) load(id string) (session *MemSession) {
+	shard := m.memory.shard(id)
+	for {
+		var version int64
+		var deleting bool
+		shard.locker.Lock()
+		session, version = shard.sessions[id], shard.version
+		_, deleting = shard.deleting[id]
+		shard.locker.Unlock()
+		if session != nil || deleting || m.Store == nil {
+			return
 		}
-		if _, ok := m.sessions[c.Value]; !ok { //if not found,reset cookie
-			ncookie()
+		loaded := m.find(shard, id)
+		if loaded == nil {
+			return
 		}
-		ss = m.sessions[c.Value]
-		ss.Flush()
-	} else {
-		if err == nil {
-			ss = m.sessions[c.Value]
+		shard.locker.Lock()
+		if session = shard.sessions[id]; session == nil && shard.version == version {
+			session = loaded
+			m.add(shard, session)
+		}
+		shard.locker.Unlock()
+		if session == loaded {
+			m.limit(session)
+			m.log("MemSessionBuilder load session %v from store", id)
+		}
+		if session != nil {
+			return
+		}
+	}
+}
+
+// find will load session from store without adding it to memory, the timeout session in store is deleted
+func (m *MemSessionBuilder) find(shard *memSessionShard, id string) (session *MemSession) {
+	values, latest, err := m.Store.Load(id)
+	if err != nil {
+		if err != ErrSessionNotFound {
+			WarnLog("MemSessionBuilder load session %v fail with %v", id, err)
 		}
+		return
 	}
-	return ss
+	if m.Timeout > 0 && m.now().Sub(latest) > m.Timeout {
+		shard.locker.Lock()
+		m.deleting(shard, id)
+		shard.locker.Unlock()
+		m.unstore(id)
+		return
+	}
+	session = m.newSession(id, latest)
+	session.Valuable = xmap.WrapSafe(values)
+	session.touched = latest.UnixNano()
+	return
+}
 
+func (m *MemSessionBuilder) newSession(id string, latest time.Time) *MemSession {
+	return &MemSession{token: id, latest: latest.UnixNano(), builder: m, index: -1}
+}
+
+// FindSession will find the session by request, the cookie is sent again when half of cookie MaxAge is passed,
+// the new session is created when value is set on Lazy mode
+func (m *MemSessionBuilder) FindSession(w http.ResponseWriter, r *http.Request) Sessionable {
+	option := m.Cookie.WithDefault(m.Domain, m.Path)
+	var session *MemSession
+	if c, err := r.Cookie(option.Name(m.CookieKey)); err == nil {
+		session = m.load(c.Value)
+	}
+	if w == nil {
+		if session == nil {
+			return nil
+		}
+		return session
+	}
+	if session == nil { //if not found,reset cookie
+		session = m.newSession(uuid.New(), m.now())
+		session.Valuable = xmap.NewSafe()
+		if m.Lazy {
+			session.pending, session.writer = 1, w
+		} else {
+			m.create(session, w)
+		}
+		return session
+	}
+	now := m.now()
+	cookie := atomic.LoadInt64(&session.cookie)
+	if cookie == 0 || (option.MaxAge > 0 && now.Sub(time.Unix(0, cookie)) > time.Duration(option.MaxAge)*time.Second/2) {
+		m.sendCookie(w, option, session.token)
+		atomic.StoreInt64(&session.cookie, now.UnixNano())
+	}
+	session.touch()
+	return session
+}
+
+// create will add new session to shard and send cookie by w
+func (m *MemSessionBuilder) create(session *MemSession, w http.ResponseWriter) {
+	now := m.now()
+	atomic.StoreInt64(&session.latest, now.UnixNano())
+	atomic.StoreInt32(&session.dirty, 1)
+	shard := m.memory.shard(session.token)
+	shard.locker.Lock()
+	m.add(shard, session)
+	shard.locker.Unlock()
+	m.limit(session)
+	if w != nil {
+		m.sendCookie(w, m.Cookie.WithDefault(m.Domain, m.Path), session.token)
+		atomic.StoreInt64(&session.cookie, now.UnixNano())
+	}
+	session.touch()
+	if event := m.handler(); event != nil {
+		event.OnCreate(session)
+	}
+}
+
+// sendCookie will send session cookie by w, the session cookie which is sent before in same response is replaced
+func (m *MemSessionBuilder) sendCookie(w http.ResponseWriter, option *CookieOption, value string) {
+	header := w.Header()
+	prefix := option.Name(m.CookieKey) + "="
+	cookies := []string{}
+	for _, cookie := range header.Values("Set-Cookie") {
+		if !strings.HasPrefix(cookie, prefix) {
+			cookies = append(cookies, cookie)
+		}
+	}
+	header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		header.Add("Set-Cookie", cookie)
+	}
+	option.SetCookie(w, m.CookieKey, value)
 }
 
 // SetEventHandler will set event handler
 func (m *MemSessionBuilder) SetEventHandler(h SessionEventHandler) {
+	m.loopLocker.Lock()
 	m.Event = h
+	m.loopLocker.Unlock()
+}
+
+// handler will return the event handler which is set by SetEventHandler
+func (m *MemSessionBuilder) handler() (h SessionEventHandler) {
+	m.loopLocker.Lock()
+	h = m.Event
+	m.loopLocker.Unlock()
+	return
+}
+
+// add will add session to memory and index the bound user by UserKey value, it must be called with shard locked
+func (m *MemSessionBuilder) add(shard *memSessionShard, session *MemSession) {
+	if len(session.user) < 1 && len(m.UserKey) > 0 {
+		session.user = session.StrDef("", m.UserKey)
+	}
+	m.memory.add(shard, session)
+}
+
+// remove will remove session from shard and user index, it is used to evict session from memory only,
+// the session in store is kept, it must be called with shard locked
+func (m *MemSessionBuilder) remove(shard *memSessionShard, session *MemSession) {
+	m.memory.remove(shard, session)
+}
+
+// destroy will remove session from memory, mark it destroyed and deleting from store, it must be called with shard locked
+// and unstore must be called after shard is unlocked
+func (m *MemSessionBuilder) destroy(shard *memSessionShard, session *MemSession) {
+	atomic.StoreInt32(&session.destroyed, 1)
+	m.remove(shard, session)
+	m.deleting(shard, session.token)
+}
+
+// deleting will mark session is deleting from store, it is not loaded from store until unstore is done,
+// it must be called with shard locked
+func (m *MemSessionBuilder) deleting(shard *memSessionShard, id string) {
+	if m.Store != nil {
+		shard.deleting[id]++
+	}
+}
+
+// unstore will delete session which is marked by deleting from store, it must be called without shard locked
+func (m *MemSessionBuilder) unstore(ids ...string) {
+	if m.Store == nil {
+		return
+	}
+	for _, id := range ids {
+		if err := m.Store.Delete(id); err != nil {
+			WarnLog("MemSessionBuilder delete session %v fail with %v", id, err)
+		}
+		shard := m.memory.shard(id)
+		shard.locker.Lock()
+		if shard.deleting[id]--; shard.deleting[id] < 1 {
+			delete(shard.deleting, id)
+		}
+		shard.version++
+		shard.locker.Unlock()
+	}
+}
+
+// loaded will return the session and its shard which is locked, the shard is not locked when error is returned
+func (m *MemSessionBuilder) loaded(s Sessionable) (shard *memSessionShard, session *MemSession, err error) {
+	session, ok := s.(*MemSession)
+	if !ok {
+		err = ErrSessionUnsupported
+		return
+	}
+	if err = session.create(); err != nil {
+		return
+	}
+	shard = m.memory.shard(session.token)
+	shard.locker.Lock()
+	if shard.sessions[session.token] != session {
+		shard.locker.Unlock()
+		err = ErrSessionNotFound
+	}
+	return
+}
+
+// Regenerate is implement for SessionLifecycle, the values and bound user is moved to new session
+func (m *MemSessionBuilder) Regenerate(w http.ResponseWriter, s Sessionable) (session Sessionable, err error) {
+	shard, old, err := m.loaded(s)
+	if err != nil {
+		return
+	}
+	m.destroy(shard, old)
+	user := old.user
+	shard.locker.Unlock()
+	m.unstore(old.token)
+	created := m.newSession(uuid.New(), m.now())
+	created.Valuable = old.Valuable
+	created.user = user
+	created.dirty = 1
+	shard = m.memory.shard(created.token)
+	shard.locker.Lock()
+	m.add(shard, created)
+	shard.locker.Unlock()
+	if w != nil {
+		m.sendCookie(w, m.Cookie.WithDefault(m.Domain, m.Path), created.token)
+		atomic.StoreInt64(&created.cookie, m.now().UnixNano())
+	}
+	if err = m.bindStore(created); err != nil {
+		return
+	}
+	m.log("MemSessionBuilder regenerate session %v to %v", old.token, created.token)
+	if event := m.handler(); event != nil {
+		event.OnRegenerate(old.token, created)
+	}
+	session = created
+	return
+}
+
+// Destroy is implement for SessionLifecycle
+func (m *MemSessionBuilder) Destroy(w http.ResponseWriter, s Sessionable) (err error) {
+	shard, session, err := m.loaded(s)
+	if err != nil {
+		return
+	}
+	m.destroy(shard, session)
+	shard.locker.Unlock()
+	m.unstore(session.token)
+	if w != nil {
+		option := m.Cookie.WithDefault(m.Domain, m.Path)
+		option.MaxAge = -1
+		m.sendCookie(w, option, "")
+	}
+	if event := m.handler(); event != nil {
+		event.OnDestroy(session)
+	}
+	return
+}
+
+// BindUser is implement for SessionLifecycle, the user is stored to session value by UserKey,
+// the oldest session of user is evicted or ErrSessionLimit is returned when user session limit is reached,
+// the session of user in memory and store is counted
+func (m *MemSessionBuilder) BindUser(s Sessionable, user string) (err error) {
+	max := int(atomic.LoadInt64(&m.maxUser))
+	others := []*MemSession{}
+	if max > 0 {
+		for _, other := range m.memory.userSessions(user) {
+			if other != s {
+				others = append(others, other)
+			}
+		}
+		others = append(others, m.storeUserSessions(user)...)
+	}
+	if max > 0 && len(others) >= max && atomic.LoadInt32(&m.rejectUser) == 1 {
+		err = ErrSessionLimit
+		return
+	}
+	shard, session, err := m.loaded(s)
+	if err != nil {
+		return
+	}
+	m.memory.bind(session, user)
+	if len(m.UserKey) > 0 {
+		session.SetValue(m.UserKey, user)
+	}
+	shard.locker.Unlock()
+	if err = m.bindStore(session); err != nil {
+		return
+	}
+	if max < 1 || len(others) < max {
+		return
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return atomic.LoadInt64(&others[i].latest) < atomic.LoadInt64(&others[j].latest)
+	})
+	//the session evicted by user limit is also deleted from store, it will be loaded again when only removed from memory
+	evicted := []*MemSession{}
+	for _, other := range others[:len(others)-max+1] {
+		if shard, loaded, xerr := m.loaded(other); xerr == nil {
+			m.destroy(shard, loaded)
+			shard.locker.Unlock()
+			m.unstore(loaded.token)
+			evicted = append(evicted, loaded)
+		} else if m.destroyStored(other) {
+			evicted = append(evicted, other)
+		}
+	}
+	m.evicted(evicted)
+	return
+}
+
+// limit will evict the least recently used session until the session count is not over max session, the except session is not evicted
+func (m *MemSessionBuilder) limit(except *MemSession) {
+	evicted := []*MemSession{}
+	for {
+		max := atomic.LoadInt64(&m.maxSession)
+		if max < 1 || int64(m.memory.Count()) <= max {
+			break
+		}
+		session := m.evictLRU(except)
+		if session == nil {
+			break
+		}
+		evicted = append(evicted, session)
+	}
+	m.evicted(evicted)
+}
+
+// evictLRU will find the shard which has the least recently used session and remove it from memory,
+// the session is kept in store and it can be loaded again
+func (m *MemSessionBuilder) evictLRU(except *MemSession) (session *MemSession) {
+	var oldest *memSessionShard
+	var latest int64
+	for _, shard := range m.memory.shards {
+		shard.locker.Lock()
+		if top := m.top(shard, except); top != nil && (oldest == nil || top.expiry < latest) {
+			oldest, latest = shard, top.expiry
+		}
+		shard.locker.Unlock()
+	}
+	if oldest == nil {
+		return
+	}
+	oldest.locker.Lock()
+	if session = m.top(oldest, except); session != nil {
+		m.remove(oldest, session)
+	}
+	oldest.locker.Unlock()
+	return
+}
+
+// top will return the least recently used session of shard except the except session, the heap top and its children
+// is fixed by latest time before choosing, so the touched session is not chosen by stale expiry, it must be called with shard locked
+func (m *MemSessionBuilder) top(shard *memSessionShard, except *MemSession) (session *MemSession) {
+	for i := 0; i < minInt(len(shard.expiry), 3); {
+		candidate := shard.expiry[i]
+		if latest := atomic.LoadInt64(&candidate.latest); latest != candidate.expiry {
+			candidate.expiry = latest
+			heap.Fix(&shard.expiry, i)
+			i = 0
+			continue
+		}
+		i++
+	}
+	for _, candidate := range shard.expiry[:minInt(len(shard.expiry), 3)] {
+		if candidate != except && (session == nil || candidate.expiry < session.expiry) {
+			session = candidate
+		}
+	}
+	return
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// evicted will log and call OnEvict event by evicted session
+func (m *MemSessionBuilder) evicted(sessions []*MemSession) {
+	if len(sessions) < 1 {
+		return
+	}
+	m.log("MemSessionBuilder evict %v session by limit", len(sessions))
+	if event := m.handler(); event != nil {
+		for _, session := range sessions {
+			event.OnEvict(session)
+		}
+	}
+}
+
+// bindStore will flush session and bind it to user in store
+func (m *MemSessionBuilder) bindStore(session *MemSession) (err error) {
+	if err = session.Flush(); err != nil || m.Store == nil || len(session.user) < 1 {
+		return
+	}
+	err = m.Store.Bind(session.token, session.user)
+	return
+}
+
+// storeUserSessions will return the session of user in store which is not loaded to memory, the session is not added to memory
+func (m *MemSessionBuilder) storeUserSessions(user string) (sessions []*MemSession) {
+	if m.Store == nil {
+		return
+	}
+	ids, err := m.Store.FindUser(user)
+	if err != nil {
+		WarnLog("MemSessionBuilder find user %v in store fail with %v", user, err)
+	}
+	now := m.now()
+	for _, id := range ids {
+		shard := m.memory.shard(id)
+		shard.locker.Lock()
+		_, loaded := shard.sessions[id]
+		shard.locker.Unlock()
+		if loaded {
+			continue
+		}
+		values, latest, err := m.Store.Load(id)
+		if err != nil || (m.Timeout > 0 && now.Sub(latest) > m.Timeout) {
+			continue
+		}
+		session := m.newSession(id, latest)
+		session.Valuable = xmap.WrapSafe(values)
+		session.user = user
+		sessions = append(sessions, session)
+	}
+	return
+}
+
+// destroyStored will delete the session which is not loaded to memory from store and mark it destroyed,
+// false is returned when it is loaded to memory
+func (m *MemSessionBuilder) destroyStored(session *MemSession) (destroyed bool) {
+	shard := m.memory.shard(session.token)
+	shard.locker.Lock()
+	if _, loaded := shard.sessions[session.token]; !loaded {
+		atomic.StoreInt32(&session.destroyed, 1)
+		m.deleting(shard, session.token)
+		destroyed = true
+	}
+	shard.locker.Unlock()
+	if destroyed {
+		m.unstore(session.token)
+	}
+	return
+}
+
+// FindByUser is implement for SessionLifecycle, the session of user in store is loaded to memory
+func (m *MemSessionBuilder) FindByUser(user string) (sessions []Sessionable) {
+	for _, stored := range m.storeUserSessions(user) {
+		m.load(stored.token)
+	}
+	for _, session := range m.memory.userSessions(user) {
+		sessions = append(sessions, session)
+	}
+	return
 }
 
-// StartTimeout will start timeout
+// DestroyUser is implement for SessionLifecycle, the session of user in store is deleted without loading to memory
+func (m *MemSessionBuilder) DestroyUser(user string) (count int, err error) {
+	destroyed := []*MemSession{}
+	for _, stored := range m.storeUserSessions(user) {
+		if m.destroyStored(stored) {
+			destroyed = append(destroyed, stored)
+		}
+	}
+	for _, session := range m.memory.userSessions(user) {
+		shard, session, xerr := m.loaded(session)
+		if xerr != nil {
+			continue
+		}
+		m.destroy(shard, session)
+		shard.locker.Unlock()
+		m.unstore(session.token)
+		destroyed = append(destroyed, session)
+	}
+	count = len(destroyed)
+	if event := m.handler(); event != nil {
+		for _, session := range destroyed {
+			event.OnDestroy(session)
+		}
+	}
+	return
+}
+
+// StartTimeout will start the loop to expire timeout session, it is not started again when it is running
 func (m *MemSessionBuilder) StartTimeout() {
-	if m.Timeout > 0 {
-		m.looping = true
-		go m.loopTimeout()
+	if m.Timeout <= 0 {
+		return
 	}
+	m.loopLocker.Lock()
+	defer m.loopLocker.Unlock()
+	if m.stopper != nil {
+		return
+	}
+	m.stopper = make(chan struct{})
+	m.waiter.Add(1)
+	go m.loopTimeout(m.stopper)
 }
 
-// StopTimeout will stop timeout
+// StopTimeout will stop the expire loop and wait it done
 func (m *MemSessionBuilder) StopTimeout() {
-	m.looping = false
-}
-
-func (m *MemSessionBuilder) loopTimeout() {
-	for m.looping {
-		ary := []string{}
-		now := time.Now()
-		m.locker.RLock()
-		for k, v := range m.sessions {
-			delay := now.Sub(v.latest)
-			if delay > m.Timeout {
-				ary = append(ary, k)
-			}
+	m.loopLocker.Lock()
+	if m.stopper != nil {
+		close(m.stopper)
+		m.stopper = nil
+	}
+	m.loopLocker.Unlock()
+	m.waiter.Wait()
+}
+
+func (m *MemSessionBuilder) loopTimeout(stopper chan struct{}) {
+	defer m.waiter.Done()
+	ticker := time.NewTicker(m.delay)
+	defer ticker.Stop()
+	var scanned time.Time
+	for {
+		m.Expire()
+		if time.Since(scanned) >= m.scanDelay {
+			m.ExpireStore()
+			scanned = time.Now()
 		}
-		m.locker.RUnlock()
-		if len(ary) > 0 {
-			m.log("looping session time out,removing (%v)", ary)
-			m.locker.Lock()
-			for _, v := range ary {
-				s := m.sessions[v]
-				delete(m.sessions, v)
-				if m.Event != nil {
-					m.Event.OnTimeout(s)
-				}
-			}
-			m.locker.Unlock()
+		select {
+		case <-stopper:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Expire will remove the timeout session in memory by Clock and return the removed count,
+// the store is deleted and OnTimeout event is called after all shard is unlocked
+func (m *MemSessionBuilder) Expire() (count int) {
+	if m.Timeout <= 0 {
+		return
+	}
+	now := m.now()
+	expired := []*MemSession{}
+	for _, shard := range m.memory.shards {
+		expired = append(expired, m.expireShard(shard, now)...)
+	}
+	if len(expired) > 0 {
+		m.log("MemSessionBuilder expire %v timeout session", len(expired))
+	}
+	for _, session := range expired {
+		m.unstore(session.token)
+	}
+	if event := m.handler(); event != nil {
+		for _, session := range expired {
+			event.OnTimeout(session)
 		}
-		time.Sleep(m.delay)
 	}
+	count = len(expired)
+	return
+}
+
+// expireShard will pop timeout session from shard heap, the touched session is pushed back by new latest time
+func (m *MemSessionBuilder) expireShard(shard *memSessionShard, now time.Time) (expired []*MemSession) {
+	shard.locker.Lock()
+	defer shard.locker.Unlock()
+	for len(shard.expiry) > 0 {
+		session := shard.expiry[0]
+		if now.Sub(time.Unix(0, session.expiry)) <= m.Timeout {
+			break
+		}
+		if latest := atomic.LoadInt64(&session.latest); latest != session.expiry {
+			session.expiry = latest
+			heap.Fix(&shard.expiry, 0)
+			continue
+		}
+		atomic.StoreInt32(&session.destroyed, 1)
+		m.remove(shard, session)
+		m.deleting(shard, session.token)
+		expired = append(expired, session)
+	}
+	return
+}
+
+// ExpireStore will delete the timeout session in store which is not loaded to memory and return the deleted count,
+// it scan all session in store, so it is called by expire loop on slower interval than Expire
+func (m *MemSessionBuilder) ExpireStore() (count int) {
+	if m.Store == nil || m.Timeout <= 0 {
+		return
+	}
+	now := m.now()
+	ary := []string{}
+	err := m.Store.Scan(func(id string, latest time.Time) bool {
+		if now.Sub(latest) > m.Timeout {
+			ary = append(ary, id)
+		}
+		return true
+	})
+	if err != nil {
+		WarnLog("MemSessionBuilder scan store fail with %v", err)
+		return
+	}
+	expired := []string{}
+	for _, id := range ary {
+		shard := m.memory.shard(id)
+		shard.locker.Lock()
+		if _, ok := shard.sessions[id]; !ok {
+			m.deleting(shard, id)
+			expired = append(expired, id)
+		}
+		shard.locker.Unlock()
+	}
+	m.unstore(expired...)
+	count = len(expired)
+	return
 }
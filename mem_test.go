@@ -3,6 +3,7 @@ package web
 import (
 	"fmt"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -99,3 +100,260 @@ func TestMemSession(t *testing.T) {
 	}
 	builder.StopTimeout()
 }
+
+func TestMemSessionExpire(t *testing.T) {
+	clock := newTestClock()
+	builder := NewMemSessionBuilder("", "/", "etest", time.Minute)
+	builder.Clock = clock
+	timeout := []string{}
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		if key == "TIMEOUT" && builder.Find(s.ID()) == nil { //not locked
+			timeout = append(timeout, s.ID())
+		}
+	}))
+	ids := []string{}
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, findSession(builder, "").ID())
+	}
+	clock.Forward(30 * time.Second)
+	findSession(builder, "etest="+ids[0])
+	regenerated, _ := builder.Regenerate(nil, builder.Find(ids[1]))
+	if count := builder.Expire(); count != 0 {
+		t.Errorf("count:%v", count)
+		return
+	}
+	clock.Forward(45 * time.Second)
+	if count := builder.Expire(); count != 998 || len(timeout) != 998 || builder.Find(ids[2]) != nil {
+		t.Errorf("count:%v,timeout:%v", count, len(timeout))
+		return
+	}
+	if builder.Find(ids[0]) == nil || builder.Find(regenerated.ID()) == nil {
+		t.Error("error")
+		return
+	}
+	clock.Forward(time.Minute)
+	if count := builder.Expire(); count != 2 {
+		t.Errorf("count:%v", count)
+		return
+	}
+	//loop
+	builder.delay = time.Millisecond
+	builder.StartTimeout()
+	builder.StartTimeout()
+	findSession(builder, "")
+	clock.Forward(2 * time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	builder.StopTimeout()
+	builder.StopTimeout()
+	if len(timeout) != 1001 {
+		t.Errorf("timeout:%v", len(timeout))
+		return
+	}
+	builder.Timeout = 0
+	if count := builder.Expire(); count != 0 {
+		t.Errorf("count:%v", count)
+		return
+	}
+	builder.StartTimeout()
+	builder.StopTimeout()
+}
+
+func TestMemSessionLimit(t *testing.T) {
+	clock := newTestClock()
+	builder := NewMemSessionBuilder("", "/", "ltest", time.Hour)
+	builder.Clock = clock
+	evicted := map[string]bool{}
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		if key == "EVICT" {
+			evicted[s.ID()] = true
+		}
+	}))
+	//max sessions
+	builder.SetMaxSessions(10)
+	ids := []string{}
+	for i := 0; i < 10; i++ {
+		clock.Forward(time.Second)
+		ids = append(ids, findSession(builder, "").ID())
+	}
+	clock.Forward(time.Second)
+	findSession(builder, "ltest="+ids[0])
+	for i := 0; i < 5; i++ {
+		clock.Forward(time.Second)
+		ids = append(ids, findSession(builder, "").ID())
+	}
+	if builder.Count() != 10 || len(evicted) != 5 || builder.Find(ids[0]) == nil {
+		t.Errorf("count:%v,evicted:%v", builder.Count(), evicted)
+		return
+	}
+	for _, id := range ids[1:6] {
+		if !evicted[id] || builder.Find(id) != nil {
+			t.Errorf("id:%v", id)
+			return
+		}
+	}
+	builder.SetMaxSessions(5)
+	if builder.Count() != 5 || len(evicted) != 10 || !evicted[ids[0]] || builder.Find(ids[10]) == nil {
+		t.Errorf("count:%v,evicted:%v", builder.Count(), evicted)
+		return
+	}
+	//max user sessions
+	builder.SetMaxSessions(0)
+	builder.SetMaxUserSessions(2, false)
+	users := []Sessionable{}
+	for i := 0; i < 3; i++ {
+		clock.Forward(time.Second)
+		session := findSession(builder, "")
+		if err := builder.BindUser(session, "u1"); err != nil {
+			t.Error(err)
+			return
+		}
+		users = append(users, session)
+	}
+	if sessions := builder.FindByUser("u1"); len(sessions) != 2 || !evicted[users[0].ID()] {
+		t.Errorf("sessions:%v", sessions)
+		return
+	}
+	builder.SetMaxUserSessions(2, true)
+	clock.Forward(time.Second)
+	if err := builder.BindUser(findSession(builder, ""), "u1"); err != ErrSessionLimit {
+		t.Error(err)
+		return
+	}
+	if err := builder.BindUser(users[2], "u1"); err != nil || len(builder.FindByUser("u1")) != 2 {
+		t.Error(err)
+		return
+	}
+	//evict with store
+	store := NewMemSessionStore()
+	builder = NewMemSessionBuilder("", "/", "ltest", time.Hour)
+	builder.Store = store
+	ids = []string{}
+	for i := 0; i < 3; i++ {
+		clock.Forward(time.Second)
+		session := findSession(builder, "")
+		session.SetValue("a", i)
+		session.Flush()
+		ids = append(ids, session.ID())
+	}
+	builder.SetMaxSessions(1)
+	if builder.Count() != 1 || store.Count() != 3 {
+		t.Errorf("count:%v,store:%v", builder.Count(), store.Count())
+		return
+	}
+	if session := builder.Find(ids[0]); session == nil || session.Int("a") != 0 || builder.Count() != 1 {
+		t.Errorf("session:%v", session)
+		return
+	}
+	//max user sessions in store
+	builder.SetMaxSessions(0)
+	if err := builder.BindUser(builder.Find(ids[0]), "u2"); err != nil {
+		t.Error(err)
+		return
+	}
+	builder = NewMemSessionBuilder("", "/", "ltest", time.Hour) //restart
+	builder.Store, builder.Clock = store, clock
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		if key == "EVICT" {
+			evicted[s.ID()] = true
+		}
+	}))
+	builder.SetMaxUserSessions(1, true)
+	clock.Forward(time.Second)
+	if err := builder.BindUser(findSession(builder, ""), "u2"); err != ErrSessionLimit {
+		t.Error(err)
+		return
+	}
+	builder.SetMaxUserSessions(1, false)
+	clock.Forward(time.Second)
+	if err := builder.BindUser(findSession(builder, ""), "u2"); err != nil || !evicted[ids[0]] || storeHaving(store, ids[0]) {
+		t.Errorf("err:%v,evicted:%v", err, evicted)
+		return
+	}
+	//least recently used by touched children
+	builder = NewMemSessionBuilder("", "/", "ltest", time.Hour)
+	shard := builder.memory.shard("lru")
+	lru := []*MemSession{}
+	for i := 0; i < 3; i++ {
+		session := builder.newSession(fmt.Sprintf("lru%v", i), time.Unix(int64(i), 0))
+		builder.memory.add(shard, session)
+		lru = append(lru, session)
+	}
+	atomic.StoreInt64(&lru[1].latest, time.Unix(10, 0).UnixNano())
+	if session := builder.top(shard, lru[0]); session != lru[2] {
+		t.Errorf("session:%v", session.ID())
+		return
+	}
+}
+
+func TestMemSessionLazy(t *testing.T) {
+	store := NewMemSessionStore()
+	builder := NewMemSessionBuilder("", "/", "lazy", time.Minute)
+	builder.Store = store
+	builder.Lazy = true
+	created := 0
+	builder.SetEventHandler(SessionEventFunc(func(key string, s Sessionable) {
+		if key == "CREATE" {
+			created++
+		}
+	}))
+	mux := NewBuilderSessionMux("", builder)
+	mux.HandleFunc("^/static$", func(s *Session) Result {
+		return s.Printf("%v", s.Str("name"))
+	})
+	mux.HandleFunc("^/set$", func(s *Session) Result {
+		s.SetValue("name", "abc")
+		return s.Printf("%v", s.ID())
+	})
+	mux.HandleFunc("^/login$", func(s *Session) Result {
+		if err := s.BindUser("u1"); err != nil {
+			return s.Printf("%v", err)
+		}
+		s.Regenerate()
+		return s.Printf("%v", s.ID())
+	})
+	mux.HandleFunc("^/late$", func(s *Session) Result {
+		s.W.Write([]byte("late"))
+		if err := s.SetValue("name", "abc"); err != ErrCookieHeaderSent {
+			s.W.Write([]byte(err.Error()))
+		}
+		return Return
+	})
+	ids := map[string]time.Time{}
+	scan := func() int {
+		ids = map[string]time.Time{}
+		store.Scan(func(id string, latest time.Time) bool {
+			ids[id] = latest
+			return true
+		})
+		return len(ids)
+	}
+	if w := serveCookie(mux, "/static", ""); w.Body.Len() != 0 || len(w.Result().Cookies()) != 0 || builder.Count() != 0 || scan() != 0 || created != 0 {
+		t.Errorf("text:%v,cookies:%v", w.Body.String(), w.Result().Cookies())
+		return
+	}
+	w := serveCookie(mux, "/set", "")
+	sid, cookies := w.Body.String(), w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != sid || builder.Count() != 1 || scan() != 1 || created != 1 {
+		t.Errorf("sid:%v,cookies:%v", sid, cookies)
+		return
+	}
+	if w = serveCookie(mux, "/static", "lazy="+sid); w.Body.String() != "abc" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("text:%v,cookies:%v", w.Body.String(), w.Result().Cookies())
+		return
+	}
+	if w = serveCookie(mux, "/static", "lazy=none"); w.Body.Len() != 0 || len(w.Result().Cookies()) != 0 || builder.Count() != 1 {
+		t.Errorf("text:%v,cookies:%v", w.Body.String(), w.Result().Cookies())
+		return
+	}
+	w = serveCookie(mux, "/login", "")
+	text, cookies := w.Body.String(), w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != text || len(builder.FindByUser("u1")) != 1 || builder.Count() != 2 || created != 2 {
+		t.Errorf("text:%v,cookies:%v", text, cookies)
+		return
+	}
+	count := scan()
+	if w = serveCookie(mux, "/late", ""); w.Body.String() != "late" || len(w.Result().Cookies()) != 0 || builder.Count() != 2 || scan() != count || created != 2 {
+		t.Errorf("text:%v,cookies:%v", w.Body.String(), w.Result().Cookies())
+		return
+	}
+}
@@ -0,0 +1,245 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentTypeProblemJSON is the content type of RFC 7807 problem details
+const ContentTypeProblemJSON = "application/problem+json"
+
+// Problem is the RFC 7807 problem details, the Extensions is flattened to json object when marshal
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+// ProblemField is the field error of validation problem
+type ProblemField struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewProblem will return new problem by http status and detail, the type is about:blank and title is status text
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// NewValidationProblem will return 400 problem by validation error, the field errors is in errors extension member
+func NewValidationProblem(err error) (problem *Problem) {
+	problem = NewProblem(http.StatusBadRequest, "request validation failed")
+	var valids ValidationErrors
+	if errors.As(err, &valids) {
+		problem.With("errors", valids)
+		return
+	}
+	fields := []*ProblemField{}
+	var binds BindErrors
+	if errors.As(err, &binds) {
+		for _, bind := range binds {
+			fields = append(fields, &ProblemField{Field: bind.Field, Message: bind.Err.Error()})
+		}
+		problem.With("errors", fields)
+		return
+	}
+	for _, line := range strings.Split(err.Error(), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) < 1 {
+			continue
+		}
+		fields = append(fields, parseProblemField(line))
+	}
+	problem.With("errors", fields)
+	return
+}
+
+// parseProblemField will parse field error from attrvalid error like limit(name,R|S,L:0),message
+func parseProblemField(line string) (field *ProblemField) {
+	field = &ProblemField{Message: line}
+	if !strings.HasPrefix(line, "limit(") {
+		return
+	}
+	temple := strings.TrimPrefix(line, "limit(")
+	if end := strings.Index(temple, "),"); end > 0 {
+		field.Message = temple[end+2:]
+		temple = temple[:end]
+	}
+	field.Field = strings.SplitN(temple, ",", 2)[0]
+	return
+}
+
+// IsValidationError will check if error is returned by attrvalid, validation or binding
+func IsValidationError(err error) bool {
+	var valids ValidationErrors
+	var binds BindErrors
+	return err != nil && (strings.HasPrefix(err.Error(), "limit(") || errors.As(err, &valids) || errors.As(err, &binds))
+}
+
+// With will set extension member
+func (p *Problem) With(key string, val interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = val
+	return p
+}
+
+func (p *Problem) Error() string {
+	if len(p.Detail) > 0 {
+		return fmt.Sprintf("%v: %v", p.Title, p.Detail)
+	}
+	return p.Title
+}
+
+// MarshalJSON will marshal problem to json object with extension members
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	values := map[string]interface{}{}
+	for key, val := range p.Extensions {
+		values[key] = val
+	}
+	if len(p.Type) > 0 {
+		values["type"] = p.Type
+	}
+	if len(p.Title) > 0 {
+		values["title"] = p.Title
+	}
+	if p.Status > 0 {
+		values["status"] = p.Status
+	}
+	if len(p.Detail) > 0 {
+		values["detail"] = p.Detail
+	}
+	if len(p.Instance) > 0 {
+		values["instance"] = p.Instance
+	}
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON will unmarshal problem from json object, the unknown members is stored to extensions
+func (p *Problem) UnmarshalJSON(data []byte) (err error) {
+	values := map[string]interface{}{}
+	if err = json.Unmarshal(data, &values); err != nil {
+		return
+	}
+	p.Type, _ = values["type"].(string)
+	p.Title, _ = values["title"].(string)
+	p.Detail, _ = values["detail"].(string)
+	p.Instance, _ = values["instance"].(string)
+	if status, ok := values["status"].(float64); ok {
+		p.Status = int(status)
+	}
+	for _, key := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(values, key)
+	}
+	if len(values) > 0 {
+		p.Extensions = values
+	}
+	return
+}
+
+// SendProblem will send problem, it is negotiated by Accept, application/problem+json is sent by default,
+// text/plain is sent when client only accept text, the Instance is request path when it is empty and p is not changed
+func (s *Session) SendProblem(p *Problem) Result {
+	if len(p.Instance) < 1 && s.R.URL != nil {
+		sending := *p
+		sending.Instance = s.R.URL.Path
+		p = &sending
+	}
+	status := p.Status
+	if status < 1 {
+		status = http.StatusInternalServerError
+	}
+	header := s.W.Header()
+	header.Set("Expires", "0")
+	switch negotiate(s.R, ContentTypeProblemJSON, "application/json", "text/plain") {
+	case "text/plain":
+		header.Set("Content-Type", ContentTypePlainText)
+		header.Set("X-Content-Type-Options", "nosniff")
+		s.W.WriteHeader(status)
+		fmt.Fprintln(s.W, p.Error())
+	case "application/json":
+		data, _ := p.MarshalJSON()
+		header.Set("Content-Type", ContentTypeJSON)
+		s.W.WriteHeader(status)
+		s.W.Write(data)
+	default:
+		data, _ := p.MarshalJSON()
+		header.Set("Content-Type", ContentTypeProblemJSON)
+		s.W.WriteHeader(status)
+		s.W.Write(data)
+	}
+	return Return
+}
+
+// SendProblemError will convert error to problem and send it by ProblemErrorMapper, the validation error is converted to 400 problem with field errors,
+// the other error is 500 problem which has debug message only when mux is debug mode
+func (s *Session) SendProblemError(err error) Result {
+	if err == nil {
+		err = ErrInternal
+	}
+	ProblemErrorMapper(s, err)
+	return Return
+}
+
+type acceptValue struct {
+	Type string
+	Q    float64
+}
+
+// parseAccept will parse Accept header to sorted media range by q-value
+func parseAccept(accept string) (values []*acceptValue) {
+	for _, part := range strings.Split(accept, ",") {
+		parts := strings.Split(strings.TrimSpace(part), ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(mediaType) < 1 {
+			continue
+		}
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			}
+		}
+		values = append(values, &acceptValue{Type: mediaType, Q: q})
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].Q > values[j].Q })
+	return
+}
+
+// negotiate will return the best offer by request Accept, the first offer is returned when Accept is empty,
+// empty is returned when no offer is acceptable
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if len(accept) < 1 {
+		return offers[0]
+	}
+	for _, value := range parseAccept(accept) {
+		if value.Q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			switch {
+			case value.Type == "*/*", value.Type == offer:
+				return offer
+			case strings.HasSuffix(value.Type, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(value.Type, "*")):
+				return offer
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestProblem(t *testing.T) {
+	shared := NewProblem(http.StatusTooManyRequests, "quota exceeded")
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/problem$", func(s *Session) Result {
+		return s.SendProblem(NewProblem(http.StatusConflict, "user exists").With("user", "u1"))
+	})
+	mux.HandleFunc("^/quota/.*$", func(s *Session) Result {
+		return s.SendProblem(shared)
+	})
+	mux.HandleFunc("^/valid$", func(s *Session) Result {
+		var name string
+		var age int64
+		err := s.ValidFormat(`
+			name,R|S,L:2~8;
+			age,R|I,R:0~150;
+		`, &name, &age)
+		return s.SendProblemError(err)
+	})
+	mux.HandleFunc("^/json$", func(s *Session) Result {
+		v := struct {
+			Name string `json:"name" valid:"name,R|S,L:2~8"`
+			Age  int    `json:"age" valid:"age,R|I,R:0~150"`
+		}{}
+		_, err := s.RecvValidJSON(&v, "", "")
+		return s.SendProblemError(err)
+	})
+	mux.HandleFunc("^/error$", func(s *Session) Result {
+		return s.SendProblemError(fmt.Errorf("db error"))
+	})
+	ts := httptest.NewServer(mux)
+	//problem
+	text, res, err := xhttp.GetHeaderText(nil, "%v/problem", ts.URL)
+	problem := xmap.M{}
+	json.Unmarshal([]byte(text), &problem)
+	if err != nil || res.StatusCode != http.StatusConflict || res.Header.Get("Content-Type") != ContentTypeProblemJSON ||
+		problem.Str("type") != "about:blank" || problem.Str("title") != "Conflict" || problem.Int("status") != 409 ||
+		problem.Str("detail") != "user exists" || problem.Str("instance") != "/problem" || problem.Str("user") != "u1" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	for _, path := range []string{"/quota/a", "/quota/b"} {
+		text, _, _ = xhttp.GetHeaderText(nil, "%v%v", ts.URL, path)
+		problem = xmap.M{}
+		json.Unmarshal([]byte(text), &problem)
+		if problem.Str("instance") != path || len(shared.Instance) > 0 {
+			t.Errorf("text:%v", text)
+			return
+		}
+	}
+	_, res, _ = xhttp.GetHeaderText(xmap.M{"Accept": "application/json"}, "%v/problem", ts.URL)
+	if res.Header.Get("Content-Type") != ContentTypeJSON {
+		t.Errorf("res:%v", res.Header)
+		return
+	}
+	text, res, _ = xhttp.GetHeaderText(xmap.M{"Accept": "text/html;q=0.9, text/*;q=0.8"}, "%v/problem", ts.URL)
+	if res.Header.Get("Content-Type") != ContentTypePlainText || strings.TrimSpace(text) != "Conflict: user exists" {
+		t.Errorf("text:%v,res:%v", text, res.Header)
+		return
+	}
+	_, res, _ = xhttp.GetHeaderText(xmap.M{"Accept": "image/png"}, "%v/problem", ts.URL)
+	if res.Header.Get("Content-Type") != ContentTypeProblemJSON {
+		t.Errorf("res:%v", res.Header)
+		return
+	}
+	//valid
+	text, res, _ = xhttp.GetHeaderText(nil, "%v/valid?name=a&age=10", ts.URL)
+	parsed := &Problem{}
+	err = json.Unmarshal([]byte(text), parsed)
+	if err != nil || res.StatusCode != http.StatusBadRequest || parsed.Status != 400 || len(parsed.Extensions["errors"].([]interface{})) != 1 {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	if field := xmap.Wrap(parsed.Extensions["errors"].([]interface{})[0]); field.Str("field") != "name" || len(field.Str("message")) < 1 {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, res, _ = xhttp.PostHeaderText(nil, strings.NewReader(`{"name":"a","age":200}`), "%v/json", ts.URL)
+	parsed = &Problem{}
+	err = json.Unmarshal([]byte(text), parsed)
+	if err != nil || res.StatusCode != http.StatusBadRequest || len(parsed.Extensions["errors"].([]interface{})) != 2 {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	//error
+	text, res, _ = xhttp.GetHeaderText(nil, "%v/error", ts.URL)
+	if res.StatusCode != http.StatusInternalServerError || strings.Contains(text, "db error") {
+		t.Errorf("text:%v", text)
+		return
+	}
+	mux.Debug = true
+	text, _, _ = xhttp.GetHeaderText(nil, "%v/error", ts.URL)
+	if !strings.Contains(text, "db error") {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//other
+	recorder := httptest.NewRecorder()
+	(&Session{W: recorder, R: httptest.NewRequest("GET", "/", nil)}).SendProblemError(nil)
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("code:%v", recorder.Code)
+		return
+	}
+	recorder = httptest.NewRecorder()
+	(&Session{W: recorder, R: httptest.NewRequest("GET", "/", nil)}).SendProblemError(NewProblem(http.StatusNotFound, ""))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("code:%v", recorder.Code)
+		return
+	}
+	recorder = httptest.NewRecorder()
+	(&Session{W: recorder, R: httptest.NewRequest("GET", "/", nil)}).SendProblem(&Problem{})
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("code:%v", recorder.Code)
+		return
+	}
+	if (&Problem{Title: "a"}).Error() != "a" || parseProblemField("xx").Message != "xx" || (&Problem{}).UnmarshalJSON([]byte("xx")) == nil {
+		t.Error("error")
+		return
+	}
+}
@@ -162,20 +162,20 @@ func (s *Session) RecvXML(v interface{}) (data []byte, err error) {
 	return
 }
 
-// RecvValidJSON will receive body, then parse to json object and valid object
+// RecvValidJSON will receive body, then parse to json object and valid object by ValidStruct
 func (s *Session) RecvValidJSON(v interface{}, filter, optional string) (data []byte, err error) {
 	data, err = converter.UnmarshalJSON(s.R.Body, v)
 	if err == nil {
-		err = Valider.Valid(v, filter, optional)
+		err = s.localizeValid(ValidStruct(v, filter, optional))
 	}
 	return
 }
 
-// RecvValideXML will receive body, then parse to xml object and valid object
+// RecvValideXML will receive body, then parse to xml object and valid object by ValidStruct
 func (s *Session) RecvValideXML(v interface{}, filter, optional string) (data []byte, err error) {
 	data, err = converter.UnmarshalXML(s.R.Body, v)
 	if err == nil {
-		err = Valider.Valid(v, filter, optional)
+		err = s.localizeValid(ValidStruct(v, filter, optional))
 	}
 	return
 }
@@ -0,0 +1,171 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	//CodeSuccess is the success code of api result
+	CodeSuccess = 0
+	//CodeArgInvalid is the error code of invalid argument
+	CodeArgInvalid = 1
+	//CodeNotAccess is the error code of not access
+	CodeNotAccess = 301
+	//CodeNotFound is the error code of not found
+	CodeNotFound = 404
+	//CodeServerError is the error code of server error
+	CodeServerError = 500
+)
+
+// ResultBody is the standard api result envelope
+type ResultBody struct {
+	Code int         `json:"code"`
+	Data interface{} `json:"data,omitempty"`
+	Msg  string      `json:"msg,omitempty"`
+	DMsg string      `json:"dmsg,omitempty"`
+	Ext  interface{} `json:"ext,omitempty"`
+	Pa   interface{} `json:"pa,omitempty"`
+}
+
+// Pagination is the pagination info of api result
+type Pagination struct {
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+	Total int64 `json:"total"`
+}
+
+// Offset will return the offset of current page
+func (p *Pagination) Offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+// ErrorCode is the application error code definition, the Key is the message key which is localized by Session.LocalValue
+type ErrorCode struct {
+	Code   int
+	Status int
+	Key    string
+}
+
+// ErrorCodeRegistry is the registry of application error code
+type ErrorCodeRegistry struct {
+	codes  map[int]*ErrorCode
+	locker sync.RWMutex
+}
+
+// NewErrorCodeRegistry will return new error code registry
+func NewErrorCodeRegistry() *ErrorCodeRegistry {
+	return &ErrorCodeRegistry{
+		codes:  map[int]*ErrorCode{},
+		locker: sync.RWMutex{},
+	}
+}
+
+// Register will register error code by http status and message key
+func (e *ErrorCodeRegistry) Register(code, status int, key string) {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	e.codes[code] = &ErrorCode{Code: code, Status: status, Key: key}
+}
+
+// Find will return error code definition, nil is returned if not registered
+func (e *ErrorCodeRegistry) Find(code int) *ErrorCode {
+	e.locker.RLock()
+	defer e.locker.RUnlock()
+	return e.codes[code]
+}
+
+// ErrorCodes is the shared error code registry
+var ErrorCodes = NewErrorCodeRegistry()
+
+func init() {
+	ErrorCodes.Register(CodeSuccess, http.StatusOK, "")
+	ErrorCodes.Register(CodeArgInvalid, http.StatusBadRequest, "error.arg_invalid")
+	ErrorCodes.Register(CodeNotAccess, http.StatusForbidden, "error.not_access")
+	ErrorCodes.Register(CodeNotFound, http.StatusNotFound, "error.not_found")
+	ErrorCodes.Register(CodeServerError, http.StatusInternalServerError, "error.server_error")
+}
+
+// RegisterErrorCode will register error code to shared registry
+func RegisterErrorCode(code, status int, key string) {
+	ErrorCodes.Register(code, status, key)
+}
+
+// SendResultBody will send api result envelope by http status
+func (s *Session) SendResultBody(status int, body *ResultBody) Result {
+	data, err := json.Marshal(body)
+	if err != nil {
+		ErrorLog("sending result(%v) fail with %s", body, err.Error())
+		http.Error(s.W, err.Error(), http.StatusInternalServerError)
+		return Return
+	}
+	header := s.W.Header()
+	header.Set("Content-Type", ContentTypeJSON)
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	header.Set("Expires", "0")
+	s.W.WriteHeader(status)
+	s.W.Write(data)
+	return Return
+}
+
+// SendResult will send success api result with data
+func (s *Session) SendResult(data interface{}) Result {
+	return s.SendResultBody(http.StatusOK, &ResultBody{Code: CodeSuccess, Data: data})
+}
+
+// SendResultExt will send success api result with data, ext and pagination
+func (s *Session) SendResultExt(data, ext, pa interface{}) Result {
+	return s.SendResultBody(http.StatusOK, &ResultBody{Code: CodeSuccess, Data: data, Ext: ext, Pa: pa})
+}
+
+// SendPage will send success api result with data and pagination
+func (s *Session) SendPage(data interface{}, pa *Pagination) Result {
+	return s.SendResultExt(data, nil, pa)
+}
+
+// SendError will send error api result by code, the http status and message is found by registered code,
+// the error message is sent as dmsg only when mux is debug mode
+func (s *Session) SendError(code int, err error) Result {
+	return s.SendErrorExt(code, err, nil)
+}
+
+// SendErrorExt will send error api result by code with ext data
+func (s *Session) SendErrorExt(code int, err error, ext interface{}) Result {
+	status, msg := http.StatusInternalServerError, ""
+	if define := ErrorCodes.Find(code); define != nil {
+		status = define.Status
+		if len(define.Key) > 0 {
+			msg = s.LocalValue(define.Key)
+		}
+	}
+	if len(msg) < 1 {
+		msg = http.StatusText(status)
+	}
+	return s.sendError(status, code, msg, err, ext)
+}
+
+func (s *Session) sendError(status, code int, msg string, err error, ext interface{}) Result {
+	body := &ResultBody{Code: code, Msg: msg, Ext: ext}
+	if err != nil && s.isDebug() {
+		body.DMsg = err.Error()
+	}
+	return s.SendResultBody(status, body)
+}
+
+// Pagination will return pagination by page/size argument, page is start from 1,
+// size is defaultSize when it is not set and not greater than maxSize
+func (s *Session) Pagination(defaultSize, maxSize int) (pa *Pagination) {
+	pa = &Pagination{Page: 1, Size: defaultSize}
+	if page, err := strconv.Atoi(s.Argument("page")); err == nil && page > 0 {
+		pa.Page = page
+	}
+	if size, err := strconv.Atoi(s.Argument("size")); err == nil && size > 0 {
+		pa.Size = size
+	}
+	if maxSize > 0 && pa.Size > maxSize {
+		pa.Size = maxSize
+	}
+	return
+}
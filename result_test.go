@@ -0,0 +1,83 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestResult(t *testing.T) {
+	RegisterErrorCode(1001, http.StatusConflict, "user.exists")
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/ok$", func(s *Session) Result {
+		return s.SendResult(xmap.M{"a": 1})
+	})
+	mux.HandleFunc("^/page$", func(s *Session) Result {
+		pa := s.Pagination(10, 20)
+		pa.Total = 100
+		return s.SendPage([]int{pa.Offset()}, pa)
+	})
+	mux.HandleFunc("^/ext$", func(s *Session) Result {
+		return s.SendResultExt("abc", xmap.M{"x": 1}, nil)
+	})
+	mux.HandleFunc("^/error$", func(s *Session) Result {
+		code := 0
+		fmt.Sscanf(s.Argument("code"), "%d", &code)
+		return s.SendError(code, fmt.Errorf("detail"))
+	})
+	mux.HandleFunc("^/invalid$", func(s *Session) Result {
+		return s.SendResult(math.NaN())
+	})
+	ts := httptest.NewServer(mux)
+	//ok
+	res, err := xhttp.GetMap("%v/ok", ts.URL)
+	if err != nil || res.Int("code") != 0 || res.Int("data/a") != 1 || res.Exist("msg") || res.Exist("pa") {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	res, err = xhttp.GetMap("%v/page?page=3&size=100", ts.URL)
+	if err != nil || res.Int("pa/page") != 3 || res.Int("pa/size") != 20 || res.Int("pa/total") != 100 || res.Int("data/0") != 40 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	res, err = xhttp.GetMap("%v/page", ts.URL)
+	if err != nil || res.Int("pa/page") != 1 || res.Int("pa/size") != 10 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	res, err = xhttp.GetMap("%v/ext", ts.URL)
+	if err != nil || res.Str("data") != "abc" || res.Int("ext/x") != 1 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//error
+	text, resp, err := xhttp.GetHeaderText(nil, "%v/error?code=1001", ts.URL)
+	res, _ = xmap.MapVal(text)
+	if err != nil || resp.StatusCode != http.StatusConflict || res.Int("code") != 1001 || res.Str("msg") != "user.exists" || res.Exist("dmsg") {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, resp, err = xhttp.GetHeaderText(nil, "%v/error?code=1", ts.URL)
+	res, _ = xmap.MapVal(text)
+	if err != nil || resp.StatusCode != http.StatusBadRequest || res.Int("code") != CodeArgInvalid {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	mux.Debug = true
+	text, resp, err = xhttp.GetHeaderText(nil, "%v/error?code=999", ts.URL)
+	res, _ = xmap.MapVal(text)
+	if err != nil || resp.StatusCode != http.StatusInternalServerError || res.Str("msg") != http.StatusText(500) || res.Str("dmsg") != "detail" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	_, resp, err = xhttp.GetHeaderText(nil, "%v/invalid", ts.URL)
+	if err != nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("err:%v,res:%v", err, resp)
+		return
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 // SendFile will send file to session
@@ -21,20 +24,26 @@ func (s *Session) SendBinary(filename, contentType string) Result {
 	return Return
 }
 
-// SendBytes string by target context type.
-func (s *Session) SendBytes(data []byte, contentType string) Result {
+// SendBytes string by target context type, options is optional to enable etag/last-modified/cache policy
+func (s *Session) SendBytes(data []byte, contentType string, options ...*SendOption) Result {
+	var option *SendOption
+	if len(options) > 0 {
+		option = options[0]
+	}
 	header := s.W.Header()
 	header.Set("Content-Type", contentType)
 	header.Set("Content-Length", fmt.Sprintf("%v", len(data)))
 	// header.Set("Content-Transfer-Encoding", "binary")
-	header.Set("Expires", "0")
+	if writeConditional(s.W, s.R, data, option) {
+		return Return
+	}
 	s.W.Write(data)
 	return Return
 }
 
-// SendString string by target context type.
-func (s *Session) SendString(data string, contentType string) Result {
-	s.SendBytes([]byte(data), contentType)
+// SendString string by target context type, options is optional to enable etag/last-modified/cache policy
+func (s *Session) SendString(data string, contentType string, options ...*SendOption) Result {
+	s.SendBytes([]byte(data), contentType, options...)
 	return Return
 }
 
@@ -43,19 +52,20 @@ func (s *Session) SendPlainText(data string) Result {
 	return s.SendBytes([]byte(data), ContentTypePlainText)
 }
 
-// SendJSON will parse value to json and send it
-func (s *Session) SendJSON(v interface{}) Result {
+// SendJSON will parse value to json and send it, options is optional to enable etag/last-modified/cache policy
+func (s *Session) SendJSON(v interface{}, options ...*SendOption) Result {
 	data, err := json.Marshal(v)
 	if err != nil {
 		ErrorLog("sending json(%v) fail with %s", v, err.Error())
 		http.Error(s.W, err.Error(), 500)
 	} else {
-		s.SendBytes(data, ContentTypeJSON)
+		s.SendBytes(data, ContentTypeJSON, options...)
 	}
 	return Return
 }
 
-// SendFile will send file to http response
+// SendFile will send file to http response, it support single/multipart range request and conditional request,
+// content type is detected by name extension or sniffing if contentType is empty.
 func SendFile(w http.ResponseWriter, r *http.Request, name, filename, contentType string, attach bool) (err error) {
 	defer func() {
 		if err != nil {
@@ -67,29 +77,57 @@ func SendFile(w http.ResponseWriter, r *http.Request, name, filename, contentTyp
 		http.NotFound(w, r)
 		return
 	}
-	err = sendf(w, src, filename, contentType, attach)
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	if len(name) < 1 {
+		name = filepath.Base(filename)
+	}
+	header := w.Header()
+	header.Set("Expires", "0")
+	SendContent(w, r, name, contentType, attach, info.ModTime(), info.Size(), src)
 	return
 }
 
-func sendf(w http.ResponseWriter, file *os.File, name, contentType string, attach bool) error {
-	fi, err := file.Stat()
-	if err == nil {
-		fsize := fi.Size()
-		header := w.Header()
-		if len(contentType) < 1 {
-			header.Set("Content-Type", "application/octet-stream")
-		} else {
-			header.Set("Content-Type", contentType)
-		}
-		if attach && len(name) > 0 {
-			header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", url.QueryEscape(name)))
-			header.Set("Content-Transfer-Encoding", "binary")
+// SendContent will send content to http response, it support single/multipart range request and conditional request
+// by modtime and etag which is generated from modtime and size, content type is detected by name extension or sniffing
+// if contentType is empty.
+func SendContent(w http.ResponseWriter, r *http.Request, name, contentType string, attach bool, modtime time.Time, size int64, content io.ReadSeeker) {
+	header := w.Header()
+	if len(contentType) > 0 {
+		header.Set("Content-Type", contentType)
+	}
+	if len(header.Get("ETag")) < 1 && !modtime.IsZero() {
+		header.Set("ETag", fmt.Sprintf(`"%x-%x"`, modtime.UnixNano(), size))
+	}
+	if attach && len(name) > 0 {
+		header.Set("Content-Disposition", ContentDisposition("attachment", path.Base(name)))
+	}
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// ContentDisposition will return Content-Disposition header value by RFC 6266, the filename* is UTF-8 encoded.
+func ContentDisposition(disposition, filename string) string {
+	fallback := []byte{}
+	encoded := &strings.Builder{}
+	for _, b := range []byte(filename) {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9', strings.IndexByte("!#$&+-.^_`|~", b) >= 0:
+			fallback = append(fallback, b)
+			encoded.WriteByte(b)
+		default:
+			if b < 0x80 && b >= 0x20 && b != '"' && b != '\\' {
+				fallback = append(fallback, b)
+			} else if b < 0x80 || b&0xC0 != 0x80 {
+				fallback = append(fallback, '_')
+			}
+			fmt.Fprintf(encoded, "%%%02X", b)
 		}
-		header.Set("Content-Length", fmt.Sprintf("%v", fsize))
-		header.Set("Expires", "0")
-		_, err = io.Copy(w, file)
 	}
-	return err
+	return fmt.Sprintf(`%v; filename="%v"; filename*=UTF-8''%v`, disposition, string(fallback), encoded.String())
 }
 
 // Printf will printf format string to http response
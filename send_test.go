@@ -1,7 +1,9 @@
 package web
 
 import (
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/codingeasygo/util/xhttp"
@@ -80,3 +82,80 @@ func TestSendFile(t *testing.T) {
 		return
 	}
 }
+
+func TestSendFileRange(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.HandleFunc("/file", func(s *Session) Result {
+		return s.SendFile("测试 a.txt", "test/test.html", "")
+	})
+	mux.HandleFunc("/binary", func(s *Session) Result {
+		return s.SendBinary("test/test.html", "")
+	})
+	mux.HandleFunc("/dir", func(s *Session) Result {
+		return s.SendBinary("test", "")
+	})
+	ts := httptest.NewServer(mux)
+	all, res, err := xhttp.GetHeaderText(nil, "%v/file", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK || len(all) < 10 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	if res.Header.Get("Accept-Ranges") != "bytes" || len(res.Header.Get("Last-Modified")) < 1 || len(res.Header.Get("ETag")) < 1 {
+		t.Errorf("err:%v,res:%v", err, res.Header)
+		return
+	}
+	if res.Header.Get("Content-Disposition") != `attachment; filename="__ a.txt"; filename*=UTF-8''%E6%B5%8B%E8%AF%95%20a.txt` {
+		t.Errorf("err:%v,res:%v", err, res.Header.Get("Content-Disposition"))
+		return
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("err:%v,res:%v", err, res.Header.Get("Content-Type"))
+		return
+	}
+	etag := res.Header.Get("ETag")
+	//single range
+	text, res, err := xhttp.GetHeaderText(xmap.M{"Range": "bytes=0-4"}, "%v/binary", ts.URL)
+	if err != nil || res.StatusCode != http.StatusPartialContent || text != all[0:5] {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("err:%v,res:%v", err, res.Header.Get("Content-Type"))
+		return
+	}
+	//multipart range
+	text, res, err = xhttp.GetHeaderText(xmap.M{"Range": "bytes=0-1,4-5"}, "%v/binary", ts.URL)
+	if err != nil || res.StatusCode != http.StatusPartialContent || !strings.HasPrefix(res.Header.Get("Content-Type"), "multipart/byteranges") {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	//if range
+	text, res, err = xhttp.GetHeaderText(xmap.M{"Range": "bytes=0-4", "If-Range": etag}, "%v/binary", ts.URL)
+	if err != nil || res.StatusCode != http.StatusPartialContent || text != all[0:5] {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	text, res, err = xhttp.GetHeaderText(xmap.M{"Range": "bytes=0-4", "If-Range": `"xx"`}, "%v/binary", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK || text != all {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	//not modified
+	_, res, err = xhttp.GetHeaderText(xmap.M{"If-None-Match": etag}, "%v/binary", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotModified {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//invalid range
+	_, res, err = xhttp.GetHeaderText(xmap.M{"Range": "bytes=100000-"}, "%v/binary", ts.URL)
+	if err != nil || res.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//dir
+	_, res, err = xhttp.GetHeaderText(nil, "%v/dir", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"encoding/json"
+)
+
+// FlashPrefix is the session value key prefix to store flash message
+const FlashPrefix = "_flash_"
+
+// Flash will append one-time message to session by key, it is removed after read by Flashes
+func (s *Session) Flash(key string, value interface{}) (err error) {
+	flashes, _ := s.Value(FlashPrefix + key).([]interface{})
+	err = s.SetValue(FlashPrefix+key, append(flashes, value))
+	return
+}
+
+// Flashes will return all flash message by key and remove them from session
+func (s *Session) Flashes(key string) (flashes []interface{}) {
+	if !s.Exist(FlashPrefix + key) {
+		return
+	}
+	flashes, _ = s.Value(FlashPrefix + key).([]interface{})
+	s.Delete(FlashPrefix + key)
+	return
+}
+
+// SessionGet will return session value by key and convert it to T by json round-tripping when it is not T,
+// so the value loaded from persistent or cookie store is converted to T, error is returned when not found
+func SessionGet[T any](s Sessionable, key string) (v T, err error) {
+	raw, err := s.ValueVal(key)
+	if err != nil {
+		return
+	}
+	if val, ok := raw.(T); ok {
+		v = val
+		return
+	}
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, &v)
+	}
+	return
+}
+
+// SessionSet will set session value by key after json round-tripping, so the value is same on memory, persistent and cookie store
+func SessionSet[T any](s Sessionable, key string, v T) (err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	var raw interface{}
+	if err = json.Unmarshal(data, &raw); err == nil {
+		err = s.SetValue(key, raw)
+	}
+	return
+}
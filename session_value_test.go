@@ -0,0 +1,130 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xmap"
+)
+
+type countSessionStore struct {
+	*MemSessionStore
+	saved int
+}
+
+func (c *countSessionStore) Save(id string, values map[string]interface{}, latest time.Time) (err error) {
+	c.saved++
+	return c.MemSessionStore.Save(id, values, latest)
+}
+
+type sessionUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestSessionValue(t *testing.T) {
+	store := &countSessionStore{MemSessionStore: NewMemSessionStore()}
+	newMux := func(builder SessionBuilder) (mux *SessionMux) {
+		mux = NewBuilderSessionMux("", builder)
+		mux.HandleFunc("^/flash$", func(s *Session) Result {
+			if err := s.Flash("info", "saved"); err != nil {
+				return s.Printf("%v", err)
+			}
+			if err := s.Flash("info", "done"); err != nil {
+				return s.Printf("%v", err)
+			}
+			if err := SessionSet(s, "user", &sessionUser{Name: "abc", Age: 10}); err != nil {
+				return s.Printf("%v", err)
+			}
+			return s.Redirect("/show")
+		})
+		mux.HandleFunc("^/show$", func(s *Session) Result {
+			user, err := SessionGet[*sessionUser](s, "user")
+			if err != nil {
+				return s.Printf("%v", err)
+			}
+			return s.Printf("%v,%v,%v", user.Name, user.Age, s.Flashes("info"))
+		})
+		mux.HandleFunc("^/read$", func(s *Session) Result {
+			return s.Printf("%v", s.Str("user/name"))
+		})
+		return
+	}
+	//mem session with store
+	builder := NewMemSessionBuilder("", "/", "vtest", time.Minute)
+	builder.Store = store
+	mux := newMux(builder)
+	cookies := serveCookie(mux, "/flash", "").Result().Cookies()
+	sid := cookies[0].Value
+	if store.saved != 1 {
+		t.Errorf("saved:%v", store.saved)
+		return
+	}
+	mux = newMux(NewMemSessionBuilder("", "/", "vtest", time.Minute)) //not found
+	if text := serveCookie(mux, "/show", "vtest="+sid).Body.String(); text == "abc,10,[saved done]" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	builder = NewMemSessionBuilder("", "/", "vtest", time.Minute)
+	builder.Store = store
+	mux = newMux(builder) //restart
+	if text := serveCookie(mux, "/show", "vtest="+sid).Body.String(); text != "abc,10,[saved done]" || store.saved != 2 {
+		t.Errorf("text:%v,saved:%v", text, store.saved)
+		return
+	}
+	if text := serveCookie(mux, "/show", "vtest="+sid).Body.String(); text != "abc,10,[]" || store.saved != 2 {
+		t.Errorf("text:%v,saved:%v", text, store.saved)
+		return
+	}
+	if text := serveCookie(mux, "/read", "vtest="+sid).Body.String(); text != "abc" || store.saved != 2 {
+		t.Errorf("text:%v,saved:%v", text, store.saved)
+		return
+	}
+	//cookie session
+	cookieBuilder, _ := NewCookieSessionBuilder("", "/", "vtest", time.Minute, []byte("key"))
+	mux = newMux(cookieBuilder)
+	cookies = serveCookie(mux, "/flash", "").Result().Cookies()
+	w := serveCookie(mux, "/show", "vtest="+cookies[0].Value)
+	text, flashed := w.Body.String(), w.Result().Cookies()
+	if text != "abc,10,[saved done]" || len(flashed) != 1 {
+		t.Errorf("text:%v,cookies:%v", text, flashed)
+		return
+	}
+	if text = serveCookie(mux, "/show", "vtest="+flashed[0].Value).Body.String(); text != "abc,10,[]" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if w = serveCookie(mux, "/read", "vtest="+flashed[0].Value); w.Body.String() != "abc" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("text:%v,cookies:%v", w.Body.String(), w.Result().Cookies())
+		return
+	}
+	//typed
+	session := &MemSession{Valuable: xmap.NewSafe()}
+	session.SetValue("user", sessionUser{Name: "x"})
+	session.SetValue("age", "x")
+	if user, err := SessionGet[sessionUser](session, "user"); err != nil || user.Name != "x" {
+		t.Errorf("err:%v,user:%v", err, user)
+		return
+	}
+	if _, err := SessionGet[int](session, "age"); err == nil {
+		t.Error(err)
+		return
+	}
+	if _, err := SessionGet[int](session, "none"); err == nil {
+		t.Error(err)
+		return
+	}
+	session.SetValue(FlashPrefix+"x", "x")
+	if err := (&Session{Sessionable: session}).Flash("x/y", "x"); err == nil {
+		t.Error(err)
+		return
+	}
+	if err := SessionSet(session, "f", func() {}); err == nil {
+		t.Error(err)
+		return
+	}
+	if session.Clear(); session.Length() != 0 {
+		t.Error("error")
+		return
+	}
+}
@@ -0,0 +1,272 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentTypeEventStream is the content type of server-sent events
+const ContentTypeEventStream = "text/event-stream"
+
+// SSEEvent is the server-sent event
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// SSEReplay is interface to replay the missed events after Last-Event-ID
+type SSEReplay interface {
+	Since(id string) []*SSEEvent
+}
+
+// SSEMemReplay is memory ring buffer implement SSEReplay, the event id is auto increment sequence
+type SSEMemReplay struct {
+	Size   int
+	events []*SSEEvent
+	seq    uint64
+	locker sync.RWMutex
+}
+
+// NewSSEMemReplay will return new memory replay buffer which keep latest size events
+func NewSSEMemReplay(size int) *SSEMemReplay {
+	return &SSEMemReplay{
+		Size:   size,
+		locker: sync.RWMutex{},
+	}
+}
+
+// Add will add event to buffer and return it with new id
+func (m *SSEMemReplay) Add(event, data string) (e *SSEEvent) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	m.seq++
+	e = &SSEEvent{ID: strconv.FormatUint(m.seq, 10), Event: event, Data: data}
+	m.events = append(m.events, e)
+	if len(m.events) > m.Size {
+		m.events = m.events[len(m.events)-m.Size:]
+	}
+	return
+}
+
+// Since will return events after id, all buffered events is returned if id is too old
+func (m *SSEMemReplay) Since(id string) (events []*SSEEvent) {
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	last, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return
+	}
+	for _, e := range m.events {
+		seq, _ := strconv.ParseUint(e.ID, 10, 64)
+		if seq > last {
+			events = append(events, e)
+		}
+	}
+	return
+}
+
+// SSEWriter is server-sent events writer, it will flush after each event and send heartbeat comment,
+// and it is closed when client disconnected.
+type SSEWriter struct {
+	LastEventID string
+	W           http.ResponseWriter
+	R           *http.Request
+	flusher     http.Flusher
+	writeLck    sync.Mutex
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// SSE will start server-sent events response with default 15s heartbeat
+func (s *Session) SSE() (writer *SSEWriter, err error) {
+	writer, err = s.SSEHeartbeat(15 * time.Second)
+	return
+}
+
+// SSEHeartbeat will start server-sent events response, heartbeat comment is sent every delay, zero is disabled
+func (s *Session) SSEHeartbeat(delay time.Duration) (writer *SSEWriter, err error) {
+	flusher := FindFlusher(s.W)
+	if flusher == nil {
+		err = fmt.Errorf("response writer %T is not supported flush", s.W)
+		return
+	}
+	header := s.W.Header()
+	header.Set("Content-Type", ContentTypeEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	s.W.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	writer = &SSEWriter{
+		LastEventID: s.R.Header.Get("Last-Event-ID"),
+		W:           s.W,
+		R:           s.R,
+		flusher:     flusher,
+		writeLck:    sync.Mutex{},
+		done:        make(chan struct{}),
+	}
+	s.addCloser(writer)
+	go writer.loopHeartbeat(delay)
+	return
+}
+
+func (w *SSEWriter) loopHeartbeat(delay time.Duration) {
+	var tick <-chan time.Time
+	if delay > 0 {
+		ticker := time.NewTicker(delay)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.R.Context().Done():
+			w.Close()
+			return
+		case <-tick:
+			w.Comment("ping")
+		}
+	}
+}
+
+func (w *SSEWriter) write(data string) (err error) {
+	w.writeLck.Lock()
+	defer w.writeLck.Unlock()
+	select {
+	case <-w.done:
+		err = fmt.Errorf("sse writer is closed")
+		return
+	default:
+	}
+	_, err = w.W.Write([]byte(data))
+	if err != nil {
+		w.closeDone()
+		return
+	}
+	w.flusher.Flush()
+	return
+}
+
+// Send will send event, data is sent directly if it is string/[]byte, or it will be marshaled to json
+func (w *SSEWriter) Send(event, id string, data interface{}) (err error) {
+	var text string
+	switch v := data.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		bys, xerr := json.Marshal(v)
+		if xerr != nil {
+			err = xerr
+			return
+		}
+		text = string(bys)
+	}
+	err = w.SendEvent(&SSEEvent{ID: id, Event: event, Data: text})
+	return
+}
+
+// SendEvent will send event
+func (w *SSEWriter) SendEvent(e *SSEEvent) (err error) {
+	buf := &strings.Builder{}
+	if len(e.ID) > 0 {
+		fmt.Fprintf(buf, "id: %v\n", sseLine(e.ID))
+	}
+	if len(e.Event) > 0 {
+		fmt.Fprintf(buf, "event: %v\n", sseLine(e.Event))
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+		fmt.Fprintf(buf, "data: %v\n", line)
+	}
+	buf.WriteString("\n")
+	err = w.write(buf.String())
+	return
+}
+
+// Comment will send comment line, it is ignored by client
+func (w *SSEWriter) Comment(text string) (err error) {
+	err = w.write(": " + sseLine(text) + "\n\n")
+	return
+}
+
+// Retry will send the reconnection time to client
+func (w *SSEWriter) Retry(delay time.Duration) (err error) {
+	err = w.write(fmt.Sprintf("retry: %v\n\n", delay.Milliseconds()))
+	return
+}
+
+// Resume will send missed events after Last-Event-ID by replay
+func (w *SSEWriter) Resume(replay SSEReplay) (err error) {
+	if len(w.LastEventID) < 1 {
+		return
+	}
+	for _, e := range replay.Since(w.LastEventID) {
+		err = w.SendEvent(e)
+		if err != nil {
+			break
+		}
+	}
+	return
+}
+
+// Done will return the channel which is closed when writer closed or client disconnected
+func (w *SSEWriter) Done() <-chan struct{} {
+	return w.done
+}
+
+// Wait will wait writer closed or client disconnected
+func (w *SSEWriter) Wait() {
+	<-w.done
+}
+
+// Close will close the writer, it is called automatic after request done
+func (w *SSEWriter) Close() (err error) {
+	w.writeLck.Lock()
+	w.closeDone()
+	w.writeLck.Unlock()
+	return
+}
+
+func (w *SSEWriter) closeDone() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+func sseLine(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
+
+type responseUnwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// findResponse will return the response writer which is T, it will check wrapped writer by Unwrap() http.ResponseWriter
+func findResponse[T any](w http.ResponseWriter) (found T, ok bool) {
+	for w != nil {
+		if found, ok = w.(T); ok {
+			return
+		}
+		unwrapper, wrapped := w.(responseUnwrapper)
+		if !wrapped {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	return
+}
+
+// FindFlusher will return http.Flusher from response writer or its wrapped writer, return nil if not found
+func FindFlusher(w http.ResponseWriter) (flusher http.Flusher) {
+	flusher, _ = findResponse[http.Flusher](w)
+	return
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (u *unwrapWriter) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+type noFlushWriter struct {
+	header http.Header
+}
+
+func (n *noFlushWriter) Header() http.Header         { return n.header }
+func (n *noFlushWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (n *noFlushWriter) WriteHeader(int)             {}
+
+func readSSE(t *testing.T, reader *bufio.Reader, lines int) (all []string) {
+	for len(all) < lines {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		line = strings.TrimSpace(line)
+		if len(line) > 0 {
+			all = append(all, line)
+		}
+	}
+	return
+}
+
+func TestSSE(t *testing.T) {
+	replay := NewSSEMemReplay(3)
+	for i := 0; i < 5; i++ {
+		replay.Add("order", "missed")
+	}
+	closed := make(chan int, 1)
+	mux := NewSessionMux("")
+	mux.FilterFunc("^.*$", func(s *Session) Result {
+		s.W = &unwrapWriter{ResponseWriter: s.W}
+		return Continue
+	})
+	mux.HandleFunc("^/sse$", func(s *Session) Result {
+		writer, err := s.SSEHeartbeat(10 * time.Millisecond)
+		if err != nil {
+			panic(err)
+		}
+		writer.Retry(time.Second)
+		writer.Resume(replay)
+		writer.Send("order", "", map[string]interface{}{"id": 1})
+		writer.Send("order", "100", "line1\nline2")
+		writer.Send("", "", []byte("bytes"))
+		writer.Wait()
+		closed <- 1
+		return Return
+	})
+	ts := httptest.NewServer(mux)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/sse", nil)
+	req.Header.Set("Last-Event-ID", "3")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil || res.Header.Get("Content-Type") != ContentTypeEventStream {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	reader := bufio.NewReader(res.Body)
+	lines := readSSE(t, reader, 14)
+	expect := []string{
+		"retry: 1000",
+		"id: 4", "event: order", "data: missed",
+		"id: 5", "event: order", "data: missed",
+		"event: order", `data: {"id":1}`,
+		"id: 100", "event: order", "data: line1", "data: line2",
+		"data: bytes",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("lines:%v", lines)
+		return
+	}
+	lines = readSSE(t, reader, 1)
+	if lines[0] != ": ping" {
+		t.Errorf("lines:%v", lines)
+		return
+	}
+	cancel()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Error("not closed")
+		return
+	}
+	//error
+	if FindFlusher(&noFlushWriter{}) != nil {
+		t.Error("error")
+		return
+	}
+	_, err = (&Session{W: &noFlushWriter{header: http.Header{}}, R: req}).SSE()
+	if err == nil {
+		t.Error(err)
+		return
+	}
+	if len(replay.Since("xx")) > 0 {
+		t.Error("error")
+		return
+	}
+}
+
+func TestSSEClose(t *testing.T) {
+	mux := NewSessionMux("")
+	writerAll := make(chan *SSEWriter, 1)
+	mux.HandleFunc("^/sse$", func(s *Session) Result {
+		writer, _ := s.SSE()
+		writer.Send("a", "1", "abc")
+		writerAll <- writer
+		return Return
+	})
+	ts := httptest.NewServer(mux)
+	res, err := http.Get(ts.URL + "/sse")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res.Body.Close()
+	writer := <-writerAll
+	writer.Wait()
+	if err = writer.Send("a", "2", "abc"); err == nil {
+		t.Error(err)
+		return
+	}
+}
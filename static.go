@@ -0,0 +1,253 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	//CacheImmutable is Cache-Control value for fingerprinted file which content is never changed
+	CacheImmutable = "public, max-age=31536000, immutable"
+	//CacheNoCache is Cache-Control value for file which should be revalidated always
+	CacheNoCache = "no-cache"
+)
+
+// StaticRule is the Cache-Control rule by path pattern for static file
+type StaticRule struct {
+	Pattern      *regexp.Regexp
+	CacheControl string
+}
+
+// Static is static file handler which serve file from http.FileSystem or fs.FS(embed.FS),
+// it support index file, SPA fallback, precompressed .br/.gz sibling and Cache-Control rules.
+type Static struct {
+	FS            http.FileSystem
+	Prefix        string        //the path prefix to trim
+	Index         string        //the index file of directory
+	Fallback      string        //the fallback file when not found, it is used by SPA, empty is disabled
+	Precompressed bool          //enable serving .br/.gz sibling file when client accepted
+	Rules         []*StaticRule //the Cache-Control rule
+	AllowDotfiles bool          //allow serving file or directory name start with .
+	AllowListing  bool          //allow listing directory when index file is not exists
+	ShowLog       bool
+}
+
+// NewStatic will return new static handler by http.FileSystem
+func NewStatic(fsys http.FileSystem) *Static {
+	return &Static{
+		FS:    fsys,
+		Index: "index.html",
+	}
+}
+
+// NewStaticDir will return new static handler by local directory
+func NewStaticDir(dir string) *Static {
+	return NewStatic(http.Dir(dir))
+}
+
+// NewStaticFS will return new static handler by fs.FS, it can be embed.FS
+func NewStaticFS(fsys fs.FS) *Static {
+	return NewStatic(http.FS(fsys))
+}
+
+// NewSPAStatic will return new static handler for single page application, which is fallback to index.html,
+// and the fingerprinted file is immutable, the html file is no-cache
+func NewSPAStatic(fsys http.FileSystem) *Static {
+	static := NewStatic(fsys)
+	static.Fallback = "index.html"
+	static.Precompressed = true
+	static.AddRule(`[\.\-][0-9a-fA-F]{8,}\.[0-9a-zA-Z]+$`, CacheImmutable)
+	static.AddRule(`\.html$`, CacheNoCache)
+	return static
+}
+
+func (s *Static) log(f string, args ...interface{}) {
+	if s.ShowLog {
+		DebugLog(f, args...)
+	}
+}
+
+// AddRule will add Cache-Control rule by path pattern, the first matched rule is used
+func (s *Static) AddRule(pattern, cacheControl string) {
+	s.Rules = append(s.Rules, &StaticRule{
+		Pattern:      regexp.MustCompile(pattern),
+		CacheControl: cacheControl,
+	})
+}
+
+func (s *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.SrvHTTP(&Session{W: w, R: r})
+}
+
+// SrvHTTP is implement for web.Handler, the 404 and 405 is sent by SendStatusPage
+func (s *Static) SrvHTTP(hs *Session) Result {
+	w, r := hs.W, hs.R
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		return hs.SendStatusPage(http.StatusMethodNotAllowed)
+	}
+	upath := strings.TrimPrefix(r.URL.Path, s.Prefix)
+	name := path.Clean("/" + upath)
+	if !s.AllowDotfiles && isDotPath(name) {
+		s.log("Static deny dot path %v", name)
+		return hs.SendStatusPage(http.StatusNotFound)
+	}
+	file, info, err := s.open(name)
+	if err == nil && info.IsDir() {
+		if !strings.HasSuffix(upath, "/") && name != "/" {
+			file.Close()
+			localRedirect(w, r, path.Base(name)+"/")
+			return Return
+		}
+		index, indexInfo, indexErr := s.open(path.Join(name, s.Index))
+		if indexErr == nil && !indexInfo.IsDir() && len(s.Index) > 0 {
+			file.Close()
+			file, info, name = index, indexInfo, path.Join(name, s.Index)
+		} else {
+			if indexErr == nil {
+				index.Close()
+			}
+			if s.AllowListing {
+				s.listDir(w, file)
+				file.Close()
+				return Return
+			}
+			file.Close()
+			err = os.ErrNotExist
+		}
+	}
+	if err != nil && len(s.Fallback) > 0 && len(path.Ext(name)) < 1 {
+		name = path.Clean("/" + s.Fallback)
+		file, info, err = s.open(name)
+	}
+	if err != nil || info.IsDir() {
+		if err == nil {
+			file.Close()
+		}
+		s.log("Static open %v fail with %v", name, err)
+		return hs.SendStatusPage(http.StatusNotFound)
+	}
+	defer file.Close()
+	header := w.Header()
+	for _, rule := range s.Rules {
+		if rule.Pattern.MatchString(name) {
+			header.Set("Cache-Control", rule.CacheControl)
+			break
+		}
+	}
+	var contentType string
+	if s.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+		for _, encoding := range []string{"br", "gzip"} {
+			if !acceptEncoding(r, encoding) {
+				continue
+			}
+			ext := ".br"
+			if encoding == "gzip" {
+				ext = ".gz"
+			}
+			encoded, encodedInfo, encodedErr := s.open(name + ext)
+			if encodedErr != nil {
+				continue
+			}
+			if encodedInfo.IsDir() {
+				encoded.Close()
+				continue
+			}
+			file.Close()
+			file, info = encoded, encodedInfo
+			contentType = mime.TypeByExtension(path.Ext(name))
+			if len(contentType) < 1 {
+				contentType = "application/octet-stream"
+			}
+			header.Set("Content-Encoding", encoding)
+			header.Set("ETag", fmt.Sprintf(`"%x-%x-%v"`, info.ModTime().UnixNano(), info.Size(), encoding))
+			break
+		}
+	}
+	s.log("Static serve %v", name)
+	SendContent(w, r, name, contentType, false, info.ModTime(), info.Size(), file)
+	return Return
+}
+
+func (s *Static) open(name string) (file http.File, info os.FileInfo, err error) {
+	file, err = s.FS.Open(name)
+	if err != nil {
+		return
+	}
+	info, err = file.Stat()
+	if err != nil {
+		file.Close()
+		file = nil
+	}
+	return
+}
+
+func (s *Static) listDir(w http.ResponseWriter, dir http.File) {
+	infos, err := dir.Readdir(-1)
+	if err != nil {
+		http.Error(w, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<pre>\n")
+	for _, info := range infos {
+		name := info.Name()
+		if !s.AllowDotfiles && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if info.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: name}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(name))
+	}
+	fmt.Fprintf(w, "</pre>\n")
+}
+
+func isDotPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func localRedirect(w http.ResponseWriter, r *http.Request, target string) {
+	if q := r.URL.RawQuery; len(q) > 0 {
+		target += "?" + q
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// acceptEncoding will check if encoding is accepted by request Accept-Encoding
+func acceptEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(parts[0]), encoding) {
+			continue
+		}
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				return err == nil && q > 0
+			}
+		}
+		return true
+	}
+	return false
+}
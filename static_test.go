@@ -0,0 +1,121 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestStatic(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":              {Data: []byte("index")},
+		"app.0123456789.js":       {Data: []byte("app")},
+		"app.0123456789.js.br":    {Data: []byte("app-br")},
+		"app.0123456789.js.gz":    {Data: []byte("app-gz")},
+		"sub/a.txt":               {Data: []byte("a")},
+		"sub/b.txt":               {Data: []byte("b")},
+		"sub/.hidden":             {Data: []byte("hidden")},
+		".env":                    {Data: []byte("env")},
+		"docs/index.html":         {Data: []byte("docs")},
+		"docs/guide/readme.json":  {Data: []byte("{}")},
+		"docs/guide/readme.json2": {Data: []byte("{}")},
+	}
+	spa := NewSPAStatic(http.FS(fsys))
+	spa.ShowLog = true
+	mux := NewSessionMux("")
+	mux.Handle("^/spa/.*$", spa)
+	spa.Prefix = "/spa"
+	static := NewStaticFS(fsys)
+	mux.Handle("^.*$", static)
+	ts := httptest.NewServer(mux)
+	//index
+	text, res, err := xhttp.GetHeaderText(nil, "%v/", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK || text != "index" {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	text, res, err = xhttp.GetHeaderText(nil, "%v/docs", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK || text != "docs" {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	//dotfile
+	_, res, err = xhttp.GetHeaderText(nil, "%v/.env", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	_, res, err = xhttp.GetHeaderText(nil, "%v/sub/.hidden", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//listing
+	_, res, err = xhttp.GetHeaderText(nil, "%v/sub/", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	static.AllowListing = true
+	text, res, err = xhttp.GetHeaderText(nil, "%v/sub/", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK || !strings.Contains(text, "a.txt") || strings.Contains(text, ".hidden") {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	static.AllowListing = false
+	//not found
+	_, res, err = xhttp.GetHeaderText(nil, "%v/not/found", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//method
+	_, res, err = xhttp.MethodText("POST", nil, nil, "%v/index.html", ts.URL)
+	if err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//spa fallback
+	text, res, err = xhttp.GetHeaderText(nil, "%v/spa/users/1", ts.URL)
+	if err != nil || res.StatusCode != http.StatusOK || text != "index" || res.Header.Get("Cache-Control") != CacheNoCache {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	_, res, err = xhttp.GetHeaderText(nil, "%v/spa/users/1.js", ts.URL)
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//precompressed
+	req, _ := http.NewRequest("GET", ts.URL+"/spa/app.0123456789.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.5, br")
+	res, err = http.DefaultTransport.RoundTrip(req)
+	if err != nil || res.Header.Get("Content-Encoding") != "br" || res.Header.Get("Cache-Control") != CacheImmutable || !strings.Contains(res.Header.Get("Content-Type"), "javascript") {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	req.Header.Set("Accept-Encoding", "gzip, br;q=0")
+	res, err = http.DefaultTransport.RoundTrip(req)
+	if err != nil || res.Header.Get("Content-Encoding") != "gzip" || res.Header.Get("Vary") != "Accept-Encoding" {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	text, res, err = xhttp.GetHeaderText(xmap.M{"Accept-Encoding": "identity"}, "%v/spa/app.0123456789.js", ts.URL)
+	if err != nil || len(res.Header.Get("Content-Encoding")) > 0 || text != "app" {
+		t.Errorf("err:%v,res:%v,text:%v", err, res, text)
+		return
+	}
+	//dir
+	w := httptest.NewRecorder()
+	NewStaticDir("test").ServeHTTP(w, httptest.NewRequest("GET", "/test.html", nil))
+	if data, _ := os.ReadFile("test/test.html"); w.Code != http.StatusOK || w.Body.String() != string(data) {
+		t.Errorf("code:%v,body:%v", w.Code, w.Body.String())
+		return
+	}
+}
@@ -0,0 +1,465 @@
+package web
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codingeasygo/util/xmap"
+)
+
+// ErrSessionNotFound is the error of session is not found in store
+var ErrSessionNotFound = errors.New("session not found")
+
+// DirtySession is the interface of session which tracks changes, SessionMux flushes it only when it is dirty,
+// the session which is not implement DirtySession is always flushed
+type DirtySession interface {
+	IsDirty() bool
+}
+
+// SessionStore is the interface to persist session values and latest time
+type SessionStore interface {
+	//Load will load session values and latest time by id, ErrSessionNotFound is returned when not found
+	Load(id string) (values map[string]interface{}, latest time.Time, err error)
+	//Save will save session values and latest time
+	Save(id string, values map[string]interface{}, latest time.Time) (err error)
+	//Delete will delete session by id
+	Delete(id string) (err error)
+	//Touch will update session latest time, ErrSessionNotFound is returned when not found
+	Touch(id string, latest time.Time) (err error)
+	//Scan will call f by each session id and latest time until f return false
+	Scan(f func(id string, latest time.Time) bool) (err error)
+	//Bind will bind session to user, the binding is removed when session is deleted, ErrSessionNotFound is returned when not found
+	Bind(id, user string) (err error)
+	//FindUser will return the session id which is bound to user
+	FindUser(user string) (ids []string, err error)
+}
+
+// memSessionHeap is the min-heap of session by latest time in heap
+type memSessionHeap []*MemSession
+
+func (h memSessionHeap) Len() int           { return len(h) }
+func (h memSessionHeap) Less(i, j int) bool { return h[i].expiry < h[j].expiry }
+func (h memSessionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *memSessionHeap) Push(x interface{}) {
+	session := x.(*MemSession)
+	session.index = len(*h)
+	*h = append(*h, session)
+}
+
+func (h *memSessionHeap) Pop() interface{} {
+	old := *h
+	session := old[len(old)-1]
+	old[len(old)-1] = nil
+	session.index = -1
+	*h = old[:len(old)-1]
+	return session
+}
+
+// memSessionShard is the shard of session map and expiry heap
+type memSessionShard struct {
+	sessions map[string]*MemSession
+	expiry   memSessionHeap
+	deleting map[string]int //the session id which is deleting from builder store
+	version  int64          //the version is increased after session is deleted from builder store
+	locker   sync.Mutex
+}
+
+const memSessionShards = 32
+
+// MemSessionStore is memory session store implement, the session is sharded by id and ordered by min-heap of latest time,
+// it is also the memory storage of MemSessionBuilder, so the session values is kept in memory only once
+type MemSessionStore struct {
+	shards     []*memSessionShard
+	count      int64
+	users      map[string]map[string]*MemSession //user to session index
+	userLocker sync.Mutex
+}
+
+// NewMemSessionStore will return new MemSessionStore
+func NewMemSessionStore() *MemSessionStore {
+	store := &MemSessionStore{users: map[string]map[string]*MemSession{}}
+	for i := 0; i < memSessionShards; i++ {
+		store.shards = append(store.shards, &memSessionShard{sessions: map[string]*MemSession{}, deleting: map[string]int{}})
+	}
+	return store
+}
+
+func (m *MemSessionStore) shard(id string) *memSessionShard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return m.shards[h.Sum32()%uint32(len(m.shards))]
+}
+
+// Count will return the session count in store
+func (m *MemSessionStore) Count() int {
+	return int(atomic.LoadInt64(&m.count))
+}
+
+// add will add session to shard, expiry heap and user index, it must be called with shard locked
+func (m *MemSessionStore) add(shard *memSessionShard, session *MemSession) {
+	shard.sessions[session.token] = session
+	atomic.AddInt64(&m.count, 1)
+	session.expiry = atomic.LoadInt64(&session.latest)
+	heap.Push(&shard.expiry, session)
+	m.index(session)
+}
+
+// remove will remove session from shard, expiry heap and user index, it must be called with shard locked
+func (m *MemSessionStore) remove(shard *memSessionShard, session *MemSession) {
+	delete(shard.sessions, session.token)
+	atomic.AddInt64(&m.count, -1)
+	if session.index >= 0 {
+		heap.Remove(&shard.expiry, session.index)
+	}
+	m.unindex(session)
+}
+
+// bind will move session to user index, it must be called with shard locked
+func (m *MemSessionStore) bind(session *MemSession, user string) {
+	m.unindex(session)
+	session.user = user
+	m.index(session)
+}
+
+// index will add session to user index
+func (m *MemSessionStore) index(session *MemSession) {
+	if len(session.user) < 1 {
+		return
+	}
+	m.userLocker.Lock()
+	if m.users[session.user] == nil {
+		m.users[session.user] = map[string]*MemSession{}
+	}
+	m.users[session.user][session.token] = session
+	m.userLocker.Unlock()
+}
+
+// unindex will remove session from user index
+func (m *MemSessionStore) unindex(session *MemSession) {
+	m.userLocker.Lock()
+	if users := m.users[session.user]; users != nil {
+		delete(users, session.token)
+		if len(users) < 1 {
+			delete(m.users, session.user)
+		}
+	}
+	m.userLocker.Unlock()
+}
+
+// userSessions will return all session of user
+func (m *MemSessionStore) userSessions(user string) (sessions []*MemSession) {
+	m.userLocker.Lock()
+	defer m.userLocker.Unlock()
+	for _, session := range m.users[user] {
+		sessions = append(sessions, session)
+	}
+	return
+}
+
+// Load is implement for SessionStore, the values is copied
+func (m *MemSessionStore) Load(id string) (values map[string]interface{}, latest time.Time, err error) {
+	shard := m.shard(id)
+	shard.locker.Lock()
+	session := shard.sessions[id]
+	shard.locker.Unlock()
+	if session == nil {
+		err = ErrSessionNotFound
+		return
+	}
+	values = session.values()
+	latest = session.Latest()
+	return
+}
+
+// Save is implement for SessionStore, the values is copied
+func (m *MemSessionStore) Save(id string, values map[string]interface{}, latest time.Time) (err error) {
+	shard := m.shard(id)
+	shard.locker.Lock()
+	defer shard.locker.Unlock()
+	session := shard.sessions[id]
+	if session == nil {
+		session = &MemSession{Valuable: xmap.NewSafe(), token: id, index: -1}
+		atomic.StoreInt64(&session.latest, latest.UnixNano())
+		m.add(shard, session)
+	}
+	replaceValues(session.Valuable, values)
+	atomic.StoreInt64(&session.latest, latest.UnixNano())
+	return
+}
+
+// Delete is implement for SessionStore
+func (m *MemSessionStore) Delete(id string) (err error) {
+	shard := m.shard(id)
+	shard.locker.Lock()
+	if session := shard.sessions[id]; session != nil {
+		m.remove(shard, session)
+	}
+	shard.locker.Unlock()
+	return
+}
+
+// Touch is implement for SessionStore
+func (m *MemSessionStore) Touch(id string, latest time.Time) (err error) {
+	shard := m.shard(id)
+	shard.locker.Lock()
+	session := shard.sessions[id]
+	shard.locker.Unlock()
+	if session == nil {
+		err = ErrSessionNotFound
+		return
+	}
+	atomic.StoreInt64(&session.latest, latest.UnixNano())
+	return
+}
+
+// Scan is implement for SessionStore, the shard is not locked when calling f
+func (m *MemSessionStore) Scan(f func(id string, latest time.Time) bool) (err error) {
+	for _, shard := range m.shards {
+		items := map[string]time.Time{}
+		shard.locker.Lock()
+		for id, session := range shard.sessions {
+			items[id] = session.Latest()
+		}
+		shard.locker.Unlock()
+		for id, latest := range items {
+			if !f(id, latest) {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Bind is implement for SessionStore
+func (m *MemSessionStore) Bind(id, user string) (err error) {
+	shard := m.shard(id)
+	shard.locker.Lock()
+	defer shard.locker.Unlock()
+	session := shard.sessions[id]
+	if session == nil {
+		err = ErrSessionNotFound
+		return
+	}
+	m.bind(session, user)
+	return
+}
+
+// FindUser is implement for SessionStore
+func (m *MemSessionStore) FindUser(user string) (ids []string, err error) {
+	for _, session := range m.userSessions(user) {
+		ids = append(ids, session.token)
+	}
+	return
+}
+
+var fileStoreID = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+const fileStoreLockers = 32
+
+// FileSessionStore is file session store implement, each session is saved to <id>.json in Dir by json,
+// the latest time is saved as file modify time, the bound user is saved to <id>.user and indexed by users/<user hash>/<id>,
+// the session file is locked by the striped locker of id
+type FileSessionStore struct {
+	Dir     string
+	lockers [fileStoreLockers]sync.RWMutex
+}
+
+// NewFileSessionStore will return new FileSessionStore, the dir is created if not exists
+func NewFileSessionStore(dir string) (store *FileSessionStore, err error) {
+	err = os.MkdirAll(dir, 0700)
+	if err == nil {
+		store = &FileSessionStore{Dir: dir}
+	}
+	return
+}
+
+func (f *FileSessionStore) filename(id string) (filename string, err error) {
+	if !fileStoreID.MatchString(id) {
+		err = ErrSessionNotFound
+		return
+	}
+	filename = filepath.Join(f.Dir, id+".json")
+	return
+}
+
+func (f *FileSessionStore) locker(id string) *sync.RWMutex {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &f.lockers[h.Sum32()%fileStoreLockers]
+}
+
+func (f *FileSessionStore) userDir(user string) string {
+	return filepath.Join(f.Dir, "users", fmt.Sprintf("%x", sha256.Sum256([]byte(user))))
+}
+
+// Load is implement for SessionStore
+func (f *FileSessionStore) Load(id string) (values map[string]interface{}, latest time.Time, err error) {
+	filename, err := f.filename(id)
+	if err != nil {
+		return
+	}
+	locker := f.locker(id)
+	locker.RLock()
+	defer locker.RUnlock()
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		err = ErrSessionNotFound
+	}
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return
+	}
+	values = map[string]interface{}{}
+	err = json.Unmarshal(data, &values)
+	latest = info.ModTime()
+	return
+}
+
+// Save is implement for SessionStore, the file is written to temp file and renamed
+func (f *FileSessionStore) Save(id string, values map[string]interface{}, latest time.Time) (err error) {
+	filename, err := f.filename(id)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return
+	}
+	locker := f.locker(id)
+	locker.Lock()
+	defer locker.Unlock()
+	tempname := filename + ".tmp"
+	if err = os.WriteFile(tempname, data, 0600); err != nil {
+		return
+	}
+	if err = os.Chtimes(tempname, latest, latest); err != nil {
+		os.Remove(tempname)
+		return
+	}
+	err = os.Rename(tempname, filename)
+	return
+}
+
+// Delete is implement for SessionStore
+func (f *FileSessionStore) Delete(id string) (err error) {
+	filename, err := f.filename(id)
+	if err != nil {
+		return
+	}
+	locker := f.locker(id)
+	locker.Lock()
+	defer locker.Unlock()
+	f.unbind(id)
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return
+}
+
+// Touch is implement for SessionStore
+func (f *FileSessionStore) Touch(id string, latest time.Time) (err error) {
+	filename, err := f.filename(id)
+	if err != nil {
+		return
+	}
+	locker := f.locker(id)
+	locker.Lock()
+	defer locker.Unlock()
+	err = os.Chtimes(filename, latest, latest)
+	if os.IsNotExist(err) {
+		err = ErrSessionNotFound
+	}
+	return
+}
+
+// Scan is implement for SessionStore
+func (f *FileSessionStore) Scan(call func(id string, latest time.Time) bool) (err error) {
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, xerr := entry.Info()
+		if xerr != nil {
+			continue
+		}
+		if !call(strings.TrimSuffix(name, ".json"), info.ModTime()) {
+			break
+		}
+	}
+	return
+}
+
+// Bind is implement for SessionStore, the user directory is kept when it is empty
+func (f *FileSessionStore) Bind(id, user string) (err error) {
+	filename, err := f.filename(id)
+	if err != nil {
+		return
+	}
+	locker := f.locker(id)
+	locker.Lock()
+	defer locker.Unlock()
+	if _, err = os.Stat(filename); os.IsNotExist(err) {
+		err = ErrSessionNotFound
+	}
+	if err != nil {
+		return
+	}
+	f.unbind(id)
+	dir := f.userDir(user)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	if err = os.WriteFile(filepath.Join(dir, id), nil, 0600); err != nil {
+		return
+	}
+	err = os.WriteFile(filepath.Join(f.Dir, id+".user"), []byte(user), 0600)
+	return
+}
+
+// unbind will remove the bound user of session, it must be called with id locked
+func (f *FileSessionStore) unbind(id string) {
+	userfile := filepath.Join(f.Dir, id+".user")
+	user, err := os.ReadFile(userfile)
+	if err != nil {
+		return
+	}
+	os.Remove(filepath.Join(f.userDir(string(user)), id))
+	os.Remove(userfile)
+}
+
+// FindUser is implement for SessionStore
+func (f *FileSessionStore) FindUser(user string) (ids []string, err error) {
+	entries, err := os.ReadDir(f.userDir(user))
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	for _, entry := range entries {
+		ids = append(ids, entry.Name())
+	}
+	return
+}
@@ -0,0 +1,298 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func testSessionStore(t *testing.T, store SessionStore) {
+	now := time.Now().Truncate(time.Second)
+	if _, _, err := store.Load("s1"); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+	if err := store.Touch("s1", now); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+	if err := store.Save("s1", map[string]interface{}{"a": "1"}, now.Add(-time.Hour)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := store.Save("s2", map[string]interface{}{"b": 2}, now); err != nil {
+		t.Error(err)
+		return
+	}
+	values, latest, err := store.Load("s1")
+	if err != nil || values["a"] != "1" || !latest.Equal(now.Add(-time.Hour)) {
+		t.Errorf("err:%v,values:%v,latest:%v", err, values, latest)
+		return
+	}
+	if err = store.Touch("s1", now); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, latest, _ = store.Load("s1"); !latest.Equal(now) {
+		t.Errorf("latest:%v", latest)
+		return
+	}
+	ids := map[string]time.Time{}
+	store.Scan(func(id string, latest time.Time) bool {
+		ids[id] = latest
+		return true
+	})
+	if len(ids) != 2 || !ids["s2"].Equal(now) {
+		t.Errorf("ids:%v", ids)
+		return
+	}
+	count := 0
+	store.Scan(func(id string, latest time.Time) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("count:%v", count)
+		return
+	}
+	if err = store.Bind("none", "u1"); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+	store.Bind("s1", "u1")
+	store.Bind("s2", "u1")
+	store.Bind("s2", "u2")
+	if ids, err := store.FindUser("u1"); err != nil || len(ids) != 1 || ids[0] != "s1" {
+		t.Errorf("err:%v,ids:%v", err, ids)
+		return
+	}
+	if ids, err := store.FindUser("u2"); err != nil || len(ids) != 1 || ids[0] != "s2" {
+		t.Errorf("err:%v,ids:%v", err, ids)
+		return
+	}
+	if err = store.Delete("s1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if ids, err := store.FindUser("u1"); err != nil || len(ids) != 0 {
+		t.Errorf("err:%v,ids:%v", err, ids)
+		return
+	}
+	if err = store.Delete("s1"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, _, err = store.Load("s1"); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+}
+
+func TestMemSessionStore(t *testing.T) {
+	testSessionStore(t, NewMemSessionStore())
+}
+
+func TestFileSessionStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	testSessionStore(t, store)
+	if _, _, err = store.Load("../s2"); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+	if err = store.Save("../s2", nil, time.Now()); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+	os.WriteFile(filepath.Join(dir, "bad.json"), []byte("{"), 0600)
+	if _, _, err = store.Load("bad"); err == nil {
+		t.Error(err)
+		return
+	}
+	if _, err = NewFileSessionStore(filepath.Join(dir, "bad.json", "x")); err == nil {
+		t.Error(err)
+		return
+	}
+}
+
+func TestMemSessionBuilderStore(t *testing.T) {
+	store, _ := NewFileSessionStore(t.TempDir())
+	newServer := func() (*MemSessionBuilder, *httptest.Server) {
+		builder := NewMemSessionBuilder("", "/", "stest", time.Minute)
+		builder.Store = store
+		mux := NewBuilderSessionMux("", builder)
+		mux.HandleFunc("^/set$", func(s *Session) Result {
+			s.SetValue("name", s.R.URL.Query().Get("name"))
+			return s.Printf("%v", s.ID())
+		})
+		mux.HandleFunc("^/get$", func(s *Session) Result {
+			return s.Printf("%v", s.Str("name"))
+		})
+		return builder, httptest.NewServer(mux)
+	}
+	_, ts := newServer()
+	sid, err := xhttp.GetText("%v/set?name=abc", ts.URL)
+	if err != nil || len(sid) < 1 {
+		t.Errorf("err:%v,sid:%v", err, sid)
+		return
+	}
+	ts.Close()
+	//restart
+	builder, ts := newServer()
+	defer ts.Close()
+	cookie := xmap.M{"Cookie": "stest=" + sid}
+	text, _, err := xhttp.GetHeaderText(cookie, "%v/get", ts.URL)
+	if err != nil || text != "abc" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	if session := builder.Find(sid); session == nil || session.Str("name") != "abc" {
+		t.Errorf("session:%v", session)
+		return
+	}
+	//no store
+	nostore := NewMemSessionBuilder("", "/", "stest", time.Minute)
+	session := findSession(nostore, "").(*MemSession)
+	if session.SetValue("name", "abc"); session.IsDirty() || session.Flush() != nil {
+		t.Error("error")
+		return
+	}
+	if values, _, err := nostore.memory.Load(session.ID()); err != nil || values["name"] != "abc" {
+		t.Errorf("err:%v,values:%v", err, values)
+		return
+	}
+	//timeout
+	builder.Timeout = 50 * time.Millisecond
+	store.Save("old", map[string]interface{}{}, time.Now().Add(-time.Hour))
+	if session := builder.Find("old"); session != nil {
+		t.Errorf("session:%v", session)
+		return
+	}
+	store.Save("old", map[string]interface{}{}, time.Now().Add(-time.Hour))
+	if builder.Expire(); !storeHaving(store, "old") {
+		t.Error("error")
+		return
+	}
+	if count := builder.ExpireStore(); count != 1 || storeHaving(store, "old") {
+		t.Errorf("count:%v", count)
+		return
+	}
+	store.Save("old", map[string]interface{}{}, time.Now().Add(-time.Hour))
+	builder.delay, builder.scanDelay = 10*time.Millisecond, 10*time.Millisecond
+	builder.StartTimeout()
+	time.Sleep(100 * time.Millisecond)
+	builder.StopTimeout()
+	if _, _, err = store.Load("old"); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+	if _, _, err = store.Load(sid); err != ErrSessionNotFound {
+		t.Errorf("err:%v", err)
+		return
+	}
+}
+
+type hookSessionStore struct {
+	*MemSessionStore
+	touched int
+	onLoad  func(id string)
+}
+
+func (h *hookSessionStore) Load(id string) (values map[string]interface{}, latest time.Time, err error) {
+	if onLoad := h.onLoad; onLoad != nil {
+		h.onLoad = nil
+		onLoad(id)
+	}
+	return h.MemSessionStore.Load(id)
+}
+
+func (h *hookSessionStore) Touch(id string, latest time.Time) (err error) {
+	h.touched++
+	return h.MemSessionStore.Touch(id, latest)
+}
+
+func TestMemSessionBuilderStoreLock(t *testing.T) {
+	clock := newTestClock()
+	store := &hookSessionStore{MemSessionStore: NewMemSessionStore()}
+	builder := NewMemSessionBuilder("", "/", "stest", time.Minute)
+	builder.Clock = clock
+	builder.Store = store
+	//touch once per tenth of timeout
+	session := findSession(builder, "")
+	session.Flush()
+	store.touched = 0
+	for i := 0; i < 3; i++ {
+		clock.Forward(time.Second)
+		findSession(builder, "stest="+session.ID())
+	}
+	if store.touched != 0 {
+		t.Errorf("touched:%v", store.touched)
+		return
+	}
+	clock.Forward(5 * time.Second)
+	findSession(builder, "stest="+session.ID())
+	findSession(builder, "stest="+session.ID())
+	if _, latest, _ := store.Load(session.ID()); store.touched != 1 || !latest.Equal(clock.Now()) {
+		t.Errorf("touched:%v,latest:%v", store.touched, latest)
+		return
+	}
+	//load without shard locked, the session deleted in loading is not loaded
+	store.Save("s1", map[string]interface{}{"_user_": "u1"}, clock.Now())
+	store.Bind("s1", "u1")
+	store.onLoad = func(id string) {
+		builder.Find(id)
+		builder.DestroyUser("u1")
+	}
+	if session := builder.Find("s1"); session != nil || builder.Count() != 1 {
+		t.Errorf("session:%v,count:%v", session, builder.Count())
+		return
+	}
+}
+
+type flushSession struct {
+	*DefaultSession
+	flushed int
+}
+
+func (f *flushSession) Flush() error {
+	f.flushed++
+	return nil
+}
+
+type flushSessionBuilder struct {
+	*DefaultSessionBuilder
+	session *flushSession
+}
+
+func (f *flushSessionBuilder) FindSession(w http.ResponseWriter, r *http.Request) Sessionable {
+	return f.session
+}
+
+func TestFlushSession(t *testing.T) {
+	builder := &flushSessionBuilder{DefaultSessionBuilder: NewDefaultSessionBuilder()}
+	builder.session = &flushSession{DefaultSession: &DefaultSession{SafeM: xmap.NewSafe()}}
+	mux := NewBuilderSessionMux("", builder)
+	mux.HandleFunc("^/get$", func(s *Session) Result {
+		return s.Printf("ok")
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/get", nil))
+	if builder.session.flushed != 1 {
+		t.Errorf("flushed:%v", builder.session.flushed)
+		return
+	}
+}
+
+func storeHaving(store SessionStore, id string) bool {
+	_, _, err := store.Load(id)
+	return err == nil
+}
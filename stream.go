@@ -0,0 +1,232 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// StreamFlushDelay is the max delay to flush the buffered stream data to client, zero is flushing after each value
+var StreamFlushDelay = 500 * time.Millisecond
+
+// StreamIterator is the func to return next value of stream, return io.EOF when done
+type StreamIterator func() (v interface{}, err error)
+
+// ChanIterator will return StreamIterator by channel, ch must be readable channel,
+// the iterator is done when channel closed
+func ChanIterator(ch interface{}) StreamIterator {
+	value := reflect.ValueOf(ch)
+	if value.Kind() != reflect.Chan || value.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic(fmt.Sprintf("%T is not readable channel", ch))
+	}
+	return func() (v interface{}, err error) {
+		recv, ok := value.Recv()
+		if !ok {
+			err = io.EOF
+			return
+		}
+		v = recv.Interface()
+		return
+	}
+}
+
+// SliceIterator will return StreamIterator by slice
+func SliceIterator(slice interface{}) StreamIterator {
+	value := reflect.ValueOf(slice)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		panic(fmt.Sprintf("%T is not slice", slice))
+	}
+	i := 0
+	return func() (v interface{}, err error) {
+		if i >= value.Len() {
+			err = io.EOF
+			return
+		}
+		v = value.Index(i).Interface()
+		i++
+		return
+	}
+}
+
+// streamWriter is the buffered writer of stream, the buffered data is flushed by StreamFlushDelay in background,
+// so the written data is sent to client when the next value is blocking
+type streamWriter struct {
+	buffer  *bufio.Writer
+	flusher http.Flusher
+	delay   time.Duration
+	pending bool //the data is written and not flushed
+	closed  bool
+	locker  sync.Mutex
+	done    chan struct{}
+}
+
+func newStreamWriter(w http.ResponseWriter) (writer *streamWriter) {
+	writer = &streamWriter{
+		buffer:  bufio.NewWriterSize(w, 32*1024),
+		flusher: FindFlusher(w),
+		delay:   StreamFlushDelay,
+		done:    make(chan struct{}),
+	}
+	if writer.delay > 0 {
+		go writer.loopFlush()
+	}
+	return
+}
+
+func (s *streamWriter) Write(p []byte) (n int, err error) {
+	s.locker.Lock()
+	n, err = s.buffer.Write(p)
+	s.pending = true
+	s.locker.Unlock()
+	return
+}
+
+func (s *streamWriter) WriteString(v string) (n int, err error) {
+	n, err = s.Write([]byte(v))
+	return
+}
+
+func (s *streamWriter) flush(force bool) (err error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.closed || (!force && !s.pending) {
+		return
+	}
+	s.pending = false
+	err = s.buffer.Flush()
+	if err == nil && s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return
+}
+
+func (s *streamWriter) loopFlush() {
+	ticker := time.NewTicker(s.delay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.flush(false)
+		}
+	}
+}
+
+// close will stop flushing in background, the writer must not be used after closed
+func (s *streamWriter) close() {
+	s.locker.Lock()
+	s.closed = true
+	s.locker.Unlock()
+	close(s.done)
+}
+
+func (s *Session) stream(contentType, begin, sep, end string, next StreamIterator) (err error) {
+	defer func() {
+		if err != nil {
+			ErrorLog("sending stream %v fail with %v", s.R.URL.Path, err)
+		}
+	}()
+	header := s.W.Header()
+	header.Set("Content-Type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Del("Content-Length")
+	writer := newStreamWriter(s.W)
+	defer writer.close()
+	done := s.R.Context().Done()
+	if _, err = writer.WriteString(begin); err != nil {
+		return
+	}
+	if err = writer.flush(true); err != nil {
+		return
+	}
+	encoder := json.NewEncoder(writer)
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			err = s.R.Context().Err()
+			return
+		default:
+		}
+		var v interface{}
+		v, err = next()
+		if err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+		if i > 0 {
+			if _, err = writer.WriteString(sep); err != nil {
+				return
+			}
+		}
+		if err = encoder.Encode(v); err != nil {
+			return
+		}
+		if writer.delay <= 0 {
+			if err = writer.flush(false); err != nil {
+				return
+			}
+		}
+	}
+	if _, err = writer.WriteString(end); err != nil {
+		return
+	}
+	err = writer.flush(true)
+	return
+}
+
+// StreamJSON will stream json array to client element by element which is returned by next, it will flush periodically
+// and abort when request context is canceled, the response is incomplete if error is returned
+func (s *Session) StreamJSON(next StreamIterator) (err error) {
+	err = s.stream(ContentTypeJSON, "[", ",", "]", next)
+	return
+}
+
+// StreamJSONChan will stream json array to client by channel until it is closed
+func (s *Session) StreamJSONChan(ch interface{}) (err error) {
+	err = s.StreamJSON(s.chanIterator(ch))
+	return
+}
+
+// StreamNDJSON will stream newline delimited json to client by next, it will flush periodically
+// and abort when request context is canceled
+func (s *Session) StreamNDJSON(next StreamIterator) (err error) {
+	err = s.stream(ContentTypeNDJSON, "", "", "", next)
+	return
+}
+
+// StreamNDJSONChan will stream newline delimited json to client by channel until it is closed
+func (s *Session) StreamNDJSONChan(ch interface{}) (err error) {
+	err = s.StreamNDJSON(s.chanIterator(ch))
+	return
+}
+
+// chanIterator will return iterator by channel which is also done when request context is canceled
+func (s *Session) chanIterator(ch interface{}) StreamIterator {
+	ChanIterator(ch) //check channel
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.R.Context().Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+	}
+	return func() (v interface{}, err error) {
+		chosen, recv, ok := reflect.Select(cases)
+		if chosen == 0 {
+			err = s.R.Context().Err()
+			return
+		}
+		if !ok {
+			err = io.EOF
+			return
+		}
+		v = recv.Interface()
+		return
+	}
+}
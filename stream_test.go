@@ -0,0 +1,195 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+func TestStreamJSON(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/array$", func(s *Session) Result {
+		s.StreamJSON(SliceIterator([]xmap.M{{"a": 1}, {"a": 2}}))
+		return Return
+	})
+	mux.HandleFunc("^/empty$", func(s *Session) Result {
+		s.StreamJSON(SliceIterator([]int{}))
+		return Return
+	})
+	mux.HandleFunc("^/chan$", func(s *Session) Result {
+		ch := make(chan int, 3)
+		ch <- 1
+		ch <- 2
+		ch <- 3
+		close(ch)
+		s.StreamJSONChan(ch)
+		return Return
+	})
+	mux.HandleFunc("^/ndjson$", func(s *Session) Result {
+		ch := make(chan string, 2)
+		ch <- "a"
+		ch <- "b"
+		close(ch)
+		s.StreamNDJSONChan(ch)
+		return Return
+	})
+	mux.HandleFunc("^/error$", func(s *Session) Result {
+		s.StreamNDJSON(func() (v interface{}, err error) {
+			err = fmt.Errorf("error")
+			return
+		})
+		return Return
+	})
+	ts := httptest.NewServer(mux)
+	//array
+	text, err := xhttp.GetText("%v/array", ts.URL)
+	if err != nil || text != "[{\"a\":1}\n,{\"a\":2}\n]" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, err = xhttp.GetText("%v/empty", ts.URL)
+	if err != nil || text != "[]" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, err = xhttp.GetText("%v/chan", ts.URL)
+	if err != nil || text != "[1\n,2\n,3\n]" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	//ndjson
+	text, resp, err := xhttp.GetHeaderText(nil, "%v/ndjson", ts.URL)
+	if err != nil || text != "\"a\"\n\"b\"\n" || resp.Header.Get("Content-Type") != ContentTypeNDJSON {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, err = xhttp.GetText("%v/error", ts.URL)
+	if err != nil || text != "" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	//panic
+	func() {
+		defer func() {
+			recover()
+		}()
+		ChanIterator(1)
+		t.Error("not panic")
+	}()
+	func() {
+		defer func() {
+			recover()
+		}()
+		SliceIterator(1)
+		t.Error("not panic")
+	}()
+}
+
+func TestStreamCancel(t *testing.T) {
+	StreamFlushDelay = 0
+	defer func() {
+		StreamFlushDelay = 500 * time.Millisecond
+	}()
+	errAll := make(chan error, 2)
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/chan$", func(s *Session) Result {
+		ch := make(chan int)
+		go func() {
+			ch <- 1
+		}()
+		errAll <- s.StreamNDJSONChan(ch)
+		return Return
+	})
+	mux.HandleFunc("^/iter$", func(s *Session) Result {
+		errAll <- s.StreamJSON(func() (v interface{}, err error) {
+			time.Sleep(10 * time.Millisecond)
+			v = 1
+			return
+		})
+		return Return
+	})
+	ts := httptest.NewServer(mux)
+	for _, path := range []string{"/chan", "/iter"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			cancel()
+			return
+		}
+		reader := bufio.NewReader(res.Body)
+		if _, err = reader.ReadString('1'); err != nil {
+			t.Error(err)
+			cancel()
+			return
+		}
+		cancel()
+		select {
+		case err = <-errAll:
+			if err == nil || err == io.EOF {
+				t.Error(err)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Error("not canceled")
+			return
+		}
+	}
+}
+
+func TestStreamBlock(t *testing.T) {
+	delay := StreamFlushDelay
+	StreamFlushDelay = 10 * time.Millisecond
+	defer func() {
+		StreamFlushDelay = delay
+	}()
+	ch := make(chan int)
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/block$", func(s *Session) Result {
+		s.StreamJSONChan(ch)
+		return Return
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	res, err := http.Get(ts.URL + "/block")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+	reader := bufio.NewReader(res.Body)
+	if begin, err := reader.ReadByte(); err != nil || begin != '[' {
+		t.Errorf("err:%v,begin:%v", err, begin)
+		return
+	}
+	ch <- 1
+	line := make(chan string, 1)
+	go func() {
+		text, _ := reader.ReadString('\n')
+		line <- text
+	}()
+	select {
+	case text := <-line:
+		if text != "1\n" {
+			t.Errorf("text:%v", text)
+			return
+		}
+	case <-time.After(time.Second):
+		t.Error("not flushed")
+		return
+	}
+	close(ch)
+	if end, err := reader.ReadString(']'); err != nil || end != "]" {
+		t.Errorf("err:%v,end:%v", err, end)
+		return
+	}
+}
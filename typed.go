@@ -0,0 +1,77 @@
+package web
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// ContentTypeXML is the content type of xml
+const ContentTypeXML = "application/xml;charset=UTF-8"
+
+// TypedFunc is the typed handler func which receive bound request and return response
+type TypedFunc[Req any, Res any] func(s *Session, req Req) (Res, error)
+
+// Typed will return handler by typed func, the struct request is bound and valid by Session.Bind, other request like slice or map is decoded from json body,
+// the response is encoded by Accept, the returned error is sent by mux ErrorMapper
+func Typed[Req any, Res any](f TypedFunc[Req, Res]) Handler {
+	return ErrorHandlerFunc(func(s *Session) (err error) {
+		var req Req
+		target := reflect.ValueOf(&req)
+		if value := target.Elem(); value.Kind() == reflect.Ptr {
+			value.Set(reflect.New(value.Type().Elem()))
+			target = value
+		}
+		if target.Elem().Kind() == reflect.Struct {
+			err = s.Bind(target.Interface())
+		} else {
+			err = s.decodeJSON(target.Interface())
+		}
+		if err != nil {
+			return
+		}
+		res, err := f(s, req)
+		if err != nil {
+			return
+		}
+		s.SendEncoded(res)
+		return
+	})
+}
+
+// decodeJSON will decode json body to target, the empty body is skipped
+func (s *Session) decodeJSON(target interface{}) (err error) {
+	if s.R.Body == nil || s.R.ContentLength == 0 {
+		return
+	}
+	if err = json.NewDecoder(s.R.Body).Decode(target); err == io.EOF {
+		err = nil
+	} else if err != nil {
+		err = ErrBadRequest.Wrap(err)
+	}
+	return
+}
+
+// SendEncoded will encode value by Accept and send it, json is default, xml is supported,
+// 204 is sent when value is nil pointer, 406 is sent when no encoding is acceptable
+func (s *Session) SendEncoded(v interface{}) Result {
+	value := reflect.ValueOf(v)
+	if v == nil || value.Kind() == reflect.Ptr && value.IsNil() {
+		s.W.WriteHeader(http.StatusNoContent)
+		return Return
+	}
+	switch negotiate(s.R, "application/json", "application/xml", "text/xml") {
+	case "application/json":
+		return s.SendJSON(v)
+	case "application/xml", "text/xml":
+		data, err := xml.Marshal(v)
+		if err != nil {
+			return s.SendErrorMapped(err)
+		}
+		return s.SendBytes(data, ContentTypeXML)
+	default:
+		return s.SendErrorMapped(NewHTTPError(http.StatusNotAcceptable, CodeArgInvalid, "not acceptable"))
+	}
+}
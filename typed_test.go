@@ -0,0 +1,210 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+type typedUserReq struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name" valid:"name,R|S,L:2~16"`
+	Tags  []string `json:"tags,omitempty"`
+	Limit *int     `json:"limit,omitempty"`
+}
+
+type typedUserRes struct {
+	ID   int64    `json:"id" xml:"id"`
+	Name string   `json:"name" xml:"name"`
+	Tags []string `json:"tags,omitempty" xml:"tags,omitempty"`
+}
+
+func TestTyped(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.HandleMethod(`^/user/(?P<id>[0-9]+)$`, Typed(func(s *Session, req *typedUserReq) (res *typedUserRes, err error) {
+		if req.ID == 404 {
+			err = ErrNotFound
+			return
+		}
+		if req.ID == 204 {
+			return
+		}
+		res = &typedUserRes{ID: req.ID, Name: req.Name, Tags: req.Tags}
+		if req.Limit != nil {
+			res.Name = fmt.Sprintf("%v-%v", res.Name, *req.Limit)
+		}
+		return
+	}), "GET,POST")
+	mux.Handle(`^/value$`, Typed(func(s *Session, req typedUserReq) (string, error) {
+		return req.Name, nil
+	}))
+	mux.Handle(`^/int$`, Typed(func(s *Session, req int) (int, error) {
+		return 1, nil
+	}))
+	mux.Handle(`^/list$`, Typed(func(s *Session, req []xmap.M) (int, error) {
+		return len(req), nil
+	}))
+	ts := httptest.NewServer(mux)
+	//query
+	res, err := xhttp.GetMap("%v/user/100?name=abc&tags=a,b&limit=3", ts.URL)
+	if err != nil || res.Int64("id") != 100 || res.Str("name") != "abc-3" || len(res.ArrayStrDef(nil, "tags")) != 2 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//json body and path override
+	text, resp, err := xhttp.PostHeaderText(xmap.M{"Content-Type": "application/json"}, strings.NewReader(`{"id":1,"name":"json","tags":["x"]}`), "%v/user/101", ts.URL)
+	res, _ = xmap.MapVal(text)
+	if err != nil || resp.StatusCode != 200 || res.Int64("id") != 101 || res.Str("name") != "json" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	//form
+	res, err = xhttp.PostFormMap(xmap.M{"name": "form"}, "%v/user/102", ts.URL)
+	if err != nil || res.Str("name") != "form" {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//xml
+	text, resp, _ = xhttp.GetHeaderText(xmap.M{"Accept": "application/xml"}, "%v/user/103?name=xml", ts.URL)
+	if resp.Header.Get("Content-Type") != ContentTypeXML || !strings.Contains(text, "<name>xml</name>") {
+		t.Errorf("text:%v", text)
+		return
+	}
+	_, resp, _ = xhttp.GetHeaderText(xmap.M{"Accept": "image/png"}, "%v/user/103?name=xml", ts.URL)
+	if resp.StatusCode != http.StatusNotAcceptable {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	//error
+	_, resp, _ = xhttp.GetHeaderText(nil, "%v/user/104?name=a", ts.URL)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	_, resp, _ = xhttp.GetHeaderText(nil, "%v/user/105?name=abc&limit=x", ts.URL)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	_, resp, _ = xhttp.PostHeaderText(xmap.M{"Content-Type": "application/json"}, strings.NewReader(`{`), "%v/user/106", ts.URL)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	_, resp, _ = xhttp.GetHeaderText(nil, "%v/user/404?name=abc", ts.URL)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	_, resp, _ = xhttp.GetHeaderText(nil, "%v/user/204?name=abc", ts.URL)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	//value
+	text, err = xhttp.GetText("%v/value?name=abc", ts.URL)
+	if err != nil || text != `"abc"` {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, err = xhttp.GetText("%v/int", ts.URL)
+	if err != nil || text != `1` {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, _, err = xhttp.PostHeaderText(xmap.M{"Content-Type": "application/json"}, strings.NewReader(`[{"a":1},{"a":2}]`), "%v/list", ts.URL)
+	if err != nil || text != `2` {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	_, resp, _ = xhttp.PostHeaderText(xmap.M{"Content-Type": "application/json"}, strings.NewReader(`{"a":1}`), "%v/list", ts.URL)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("res:%v", resp)
+		return
+	}
+	//path params
+	session := &Session{R: httptest.NewRequest("GET", "/user/1", nil)}
+	if len(session.PathParams()) > 0 || session.PathParam("id") != "" {
+		t.Error("error")
+		return
+	}
+}
+
+func TestBindValue(t *testing.T) {
+	v := struct {
+		bindEmbedded
+		private  int
+		Skip     string        `json:"-"`
+		Bool     bool          `json:"bool"`
+		Int8     int8          `json:"int8"`
+		Uint     uint          `json:"uint"`
+		Float    float64       `json:"float"`
+		Bytes    []byte        `json:"bytes"`
+		Time     time.Time     `json:"time"`
+		Date     *time.Time    `json:"date"`
+		Duration time.Duration `json:"duration"`
+		Ints     []int         `json:"ints"`
+		Text     testText      `json:"text"`
+	}{}
+	values := map[string][]string{
+		"embedded": {"e"},
+		"Skip":     {"x"},
+		"bool":     {"true"},
+		"int8":     {"8"},
+		"uint":     {"9"},
+		"float":    {"1.5"},
+		"bytes":    {"abc"},
+		"time":     {"1577836800000"},
+		"date":     {"2020-01-02"},
+		"duration": {"1s"},
+		"ints":     {"1", "2"},
+		"text":     {"abc"},
+	}
+	lookup := func(field reflect.StructField) (name, source string, vals []string, ok bool) {
+		name = bindName(field)
+		vals, ok = values[name]
+		return
+	}
+	err := bindFields(reflect.ValueOf(&v), lookup)
+	if err != nil || v.bindEmbedded.Embedded != "e" || len(v.Skip) > 0 || !v.Bool || v.Int8 != 8 || v.Uint != 9 || v.Float != 1.5 || string(v.Bytes) != "abc" ||
+		v.Time.UnixMilli() != 1577836800000 || v.Date.Day() != 2 || v.Duration != time.Second || len(v.Ints) != 2 || v.Text != "ABC" {
+		t.Errorf("err:%v,v:%v", err, v)
+		return
+	}
+	for key, val := range map[string]string{"bool": "x", "int8": "1000", "uint": "-1", "float": "x", "time": "x", "duration": "x", "ints": "a,b"} {
+		values = map[string][]string{key: {val}}
+		if err = bindFields(reflect.ValueOf(&v), lookup); err == nil {
+			t.Errorf("%v:%v", key, val)
+			return
+		}
+	}
+	values = map[string][]string{"m": {"1"}}
+	if err = bindFields(reflect.ValueOf(&struct {
+		M map[string]string `json:"m"`
+	}{}), lookup); err == nil {
+		t.Error(err)
+		return
+	}
+	if err = bindFields(reflect.ValueOf(1), lookup); err == nil {
+		t.Error(err)
+		return
+	}
+}
+
+type bindEmbedded struct {
+	Embedded string `json:"embedded"`
+}
+
+type testText string
+
+func (t *testText) UnmarshalText(text []byte) error {
+	*t = testText(strings.ToUpper(string(text)))
+	return nil
+}
@@ -0,0 +1,270 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/codingeasygo/util/attrvalid"
+)
+
+// ValidationError is the error of one field validation, the Key is used to localize Message
+type ValidationError struct {
+	Field   string   `json:"field"`            //the field name in valid temple
+	Path    string   `json:"path"`             //the json path of field, like items[0].name
+	Rule    string   `json:"rule"`             //the failed rule, like required/type/length/range/option/pattern/enum
+	Params  []string `json:"params,omitempty"` //the rule params, like 2,8 for L:2~8
+	Message string   `json:"message"`
+	Key     string   `json:"key"` //the local key, it is valid.<rule> or the custom message in valid temple
+}
+
+func (v *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v", v.Path, v.Message)
+}
+
+// ValidationErrors is the errors of all failed fields
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, err := range v {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// FormatValidationError is the error of Session.ValidFormat, the Error is same as attrvalid.ValidAttrFormat for compatibility,
+// the all failed fields is kept in Errors and also returned by errors.As with ValidationErrors
+type FormatValidationError struct {
+	Origin string //the attrvalid error message of first failed temple
+	Errors ValidationErrors
+}
+
+func (f *FormatValidationError) Error() string {
+	return f.Origin
+}
+
+// Unwrap will return the ValidationErrors
+func (f *FormatValidationError) Unwrap() error {
+	return f.Errors
+}
+
+// Localize will replace message by local func, the field key like valid.<field>.<rule> is tried before valid.<rule>,
+// the custom message key in valid temple is tried first, the message is kept when key is not found by local func,
+// the field, path and params is passed as named argument, the min/max is passed for length/range rule
+func (v ValidationErrors) Localize(local func(key string, args ...interface{}) string) {
+	for _, err := range v {
+		args := err.localArgs()
+		keys := []string{"valid." + err.Field + "." + err.Rule, err.Key}
+		if err.Key != "valid."+err.Rule {
+			keys[0], keys[1] = keys[1], keys[0]
+		}
+		for _, key := range keys {
+			if msg := local(key, args); len(msg) > 0 && msg != key {
+				err.Message = msg
+				break
+			}
+		}
+	}
+}
+
+func (v *ValidationError) localArgs() map[string]interface{} {
+	args := map[string]interface{}{
+		"field":  v.Field,
+		"path":   v.Path,
+		"params": strings.Join(v.Params, ", "),
+	}
+	if (v.Rule == "length" || v.Rule == "range") && len(v.Params) > 1 {
+		args["min"], args["max"] = v.Params[0], v.Params[1]
+	}
+	return args
+}
+
+// newValidationError will return validation error by valid temple parts and the error of attrvalid.ValidAttrTemple
+func newValidationError(path string, parts []string, err error) (verr *ValidationError) {
+	verr = &ValidationError{Field: parts[0], Path: path, Message: err.Error()}
+	verr.Rule, verr.Params = validRule(parts, err)
+	verr.Key = "valid." + verr.Rule
+	if len(parts) > 3 {
+		verr.Message = parts[3]
+		verr.Key = parts[3]
+	}
+	return
+}
+
+// validRule will return the failed rule and params by valid temple parts and error
+func validRule(parts []string, err error) (rule string, params []string) {
+	msg := err.Error()
+	types := strings.SplitN(parts[1], "|", 2)
+	ranges := strings.SplitN(parts[2], ":", 2)
+	switch {
+	case msg == "data is empty":
+		return "required", nil
+	case strings.HasPrefix(msg, "invalid value(") && strings.Contains(msg, "for type(") && len(types) > 1:
+		return "type", []string{types[1]}
+	case len(ranges) < 2:
+		return "invalid", nil
+	}
+	switch strings.ToUpper(ranges[0]) {
+	case "O":
+		rule, params = "option", strings.Split(ranges[1], "~")
+	case "L":
+		rule, params = "length", strings.Split(ranges[1], "~")
+	case "R":
+		rule, params = "range", strings.Split(ranges[1], "~")
+	case "P":
+		rule, params = "pattern", []string{ranges[1]}
+	case "E":
+		rule = "enum"
+	default:
+		rule = "invalid"
+	}
+	return
+}
+
+var validComment = regexp.MustCompile(`\/\/.*`)
+
+// validFormat will valid value by format like attrvalid.ValidAttrFormat, but all temple is checked and FormatValidationError is returned,
+// the other error like args count not match is returned directly
+func validFormat(getter attrvalid.ValueGetter, format string, args ...interface{}) (err error) {
+	format = validComment.ReplaceAllString(format, "")
+	format = strings.Replace(format, "\n", "", -1)
+	format = strings.Trim(format, " \t;")
+	temples := strings.Split(format, ";")
+	if len(format) < 1 || (len(args) > 0 && len(args) != len(temples)) {
+		err = attrvalid.ValidAttrFormat(format, getter, true, args...)
+		return
+	}
+	verr := &FormatValidationError{}
+	for i, temple := range temples {
+		var arg interface{}
+		if len(args) > 0 {
+			arg = args[i]
+		}
+		parts := strings.SplitN(strings.TrimSpace(temple), ",", 4)
+		if len(parts) < 3 {
+			err = attrvalid.ValidAttrFormat(temple, getter, true, arg)
+			return
+		}
+		limit := strings.Join(parts[:3], ",")
+		xerr := attrvalid.ValidAttrFormat(limit, getter, true, arg)
+		if xerr == nil {
+			continue
+		}
+		prefix := "limit(" + limit + "),"
+		if !strings.HasPrefix(xerr.Error(), prefix) {
+			err = xerr
+			return
+		}
+		if len(verr.Errors) < 1 {
+			verr.Origin = "limit(" + strings.TrimSpace(temple) + ")," + strings.TrimPrefix(xerr.Error(), prefix)
+			if len(parts) > 3 {
+				verr.Origin = parts[3]
+			}
+		}
+		verr.Errors = append(verr.Errors, newValidationError(parts[0], parts, errors.New(strings.TrimPrefix(xerr.Error(), prefix))))
+	}
+	if len(verr.Errors) > 0 {
+		err = verr
+	}
+	return
+}
+
+// ValidStruct will valid struct by valid tag like Valider.Valid, but all field is checked and ValidationErrors is returned,
+// the nested struct and slice of struct is valid recursively and the path is json path like items[0].name
+func ValidStruct(target interface{}, filter, optional string) (err error) {
+	optional = strings.TrimSpace(optional)
+	isExc := strings.HasPrefix(optional, "^")
+	optional = "," + strings.Trim(strings.TrimPrefix(optional, "^"), ",") + ","
+	required := func(fieldName string) bool {
+		return strings.Contains(optional, ","+fieldName+",") == isExc
+	}
+	nested := ""
+	if parts := strings.SplitN(filter, "#", 2); len(parts) > 1 {
+		nested = "#" + parts[1]
+	}
+	errs := validFields(target, "", filter, nested, required)
+	if len(errs) > 0 {
+		err = errs
+	}
+	return
+}
+
+func validFields(target interface{}, path, filter, nested string, required func(fieldName string) bool) (errs ValidationErrors) {
+	Valider.FilterFieldCall("valid", target, filter, func(fieldName, fieldFunc string, field reflect.StructField, value interface{}) {
+		valid := strings.TrimSuffix(strings.TrimSpace(field.Tag.Get("valid")), ";")
+		fieldPath := fieldName
+		if len(path) > 0 {
+			fieldPath = path + "." + fieldName
+		}
+		if valid == "inline" {
+			errs = append(errs, validFields(value, path, filter, nested, required)...)
+			return
+		}
+		targetValue := reflect.Indirect(reflect.ValueOf(value))
+		if len(valid) > 0 && valid != "-" {
+			enum, _ := value.(attrvalid.EnumValider)
+			errs = append(errs, validValue(targetValue, fieldPath, valid, required(fieldName), enum)...)
+		}
+		errs = append(errs, validNested(targetValue, fieldPath, nested)...)
+	})
+	return
+}
+
+func validValue(target reflect.Value, path, valid string, required bool, enum attrvalid.EnumValider) (errs ValidationErrors) {
+	parts := strings.SplitN(valid, ",", 4)
+	if len(parts) < 3 {
+		errs = append(errs, &ValidationError{Field: parts[0], Path: path, Rule: "invalid", Message: "valid error:" + valid, Key: "valid.invalid"})
+		return
+	}
+	if target.Kind() != reflect.Slice || target.Type().Elem().Kind() == reflect.Uint8 {
+		if _, err := attrvalid.ValidAttrTemple(target.Interface(), parts[1], parts[2], required, enum); err != nil {
+			errs = append(errs, newValidationError(path, parts, err))
+		}
+		return
+	}
+	if target.Len() < 1 {
+		if _, err := attrvalid.ValidAttrTemple(nil, parts[1], parts[2], required, enum); err != nil {
+			errs = append(errs, newValidationError(path, parts, err))
+		}
+		return
+	}
+	for i := 0; i < target.Len(); i++ {
+		if _, err := attrvalid.ValidAttrTemple(target.Index(i).Interface(), parts[1], parts[2], required, enum); err != nil {
+			errs = append(errs, newValidationError(fmt.Sprintf("%v[%v]", path, i), parts, err))
+		}
+	}
+	return
+}
+
+func validNested(target reflect.Value, path, filter string) (errs ValidationErrors) {
+	switch target.Kind() {
+	case reflect.Ptr:
+		if !target.IsNil() {
+			errs = validNested(target.Elem(), path, filter)
+		}
+	case reflect.Struct:
+		if target.Type() != typeTime && target.CanAddr() {
+			errs = validFields(target.Addr().Interface(), path, filter, filter, func(string) bool { return true })
+		}
+	case reflect.Slice, reflect.Array:
+		if kind := target.Type().Elem().Kind(); kind != reflect.Struct && kind != reflect.Ptr {
+			return
+		}
+		for i := 0; i < target.Len(); i++ {
+			errs = append(errs, validNested(target.Index(i), fmt.Sprintf("%v[%v]", path, i), filter)...)
+		}
+	}
+	return
+}
+
+// localizeValid will localize message of ValidationErrors by session local
+func (s *Session) localizeValid(err error) error {
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		errs.Localize(s.LocalValue)
+	}
+	return err
+}
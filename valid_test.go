@@ -0,0 +1,145 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codingeasygo/util/attrvalid"
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+)
+
+type validItem struct {
+	Name  string `json:"name" valid:"name,R|S,L:1~9"`
+	Count int    `json:"count" valid:"count,R|I,R:0~11"`
+}
+
+type validOrder struct {
+	Title  string       `json:"title" valid:"title,R|S,L:1~17"`
+	Status string       `json:"status" valid:"status,R|S,O:a~b,order.status_invalid"`
+	Tags   []string     `json:"tags" valid:"tags,O|S,L:0~5"`
+	Owner  *validItem   `json:"owner"`
+	Items  []*validItem `json:"items"`
+	Inline validItem    `json:"inline" valid:"inline"`
+}
+
+func TestValidationErrors(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.HandleFunc("^/format$", func(s *Session) Result {
+		var name string
+		var age int64
+		var email string
+		err := s.ValidFormat(`
+			name,R|S,L:2~8;
+			age,R|I,R:0~150;
+			email,R|S,P:^.*@.*$;
+		`, &name, &age, &email)
+		if err != nil {
+			return s.SendProblemError(err)
+		}
+		return s.Printf("%v-%v-%v", name, age, email)
+	})
+	mux.HandleFunc("^/json$", func(s *Session) Result {
+		v := &validOrder{}
+		_, err := s.RecvValidJSON(v, "#all", "")
+		if err != nil {
+			return s.SendProblemError(err)
+		}
+		return s.Printf("%v", v.Title)
+	})
+	ts := httptest.NewServer(mux)
+	//format
+	text, err := xhttp.GetText("%v/format?name=abc&age=10&email=a@b", ts.URL)
+	if err != nil || text != "abc-10-a@b" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	text, resp, _ := xhttp.GetHeaderText(nil, "%v/format?name=a&age=x", ts.URL)
+	res, _ := xmap.MapVal(text)
+	errs := res.ArrayMapDef(nil, "errors")
+	if resp.StatusCode != http.StatusBadRequest || len(errs) != 3 ||
+		errs[0].Str("field") != "name" || errs[0].Str("rule") != "length" || errs[0].Str("key") != "valid.length" || len(errs[0].ArrayStrDef(nil, "params")) != 2 ||
+		errs[1].Str("rule") != "type" || errs[2].Str("path") != "email" || errs[2].Str("rule") != "required" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	//nested
+	body := `{"title":"t","status":"x","tags":["a","abcdef"],"owner":{"name":"o","count":1},"items":[{"name":"ok","count":1},{"name":"x","count":20}],"inline":{"name":"in","count":0}}`
+	text, resp, _ = xhttp.PostHeaderText(nil, strings.NewReader(body), "%v/json", ts.URL)
+	res, _ = xmap.MapVal(text)
+	paths := []string{}
+	for _, e := range res.ArrayMapDef(nil, "errors") {
+		paths = append(paths, e.Str("path"))
+	}
+	if resp.StatusCode != http.StatusBadRequest || strings.Join(paths, ",") != "title,status,tags[1],owner.name,items[1].name,items[1].count,count" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	if res.StrDef("", "errors/1/key") != "order.status_invalid" || res.StrDef("", "errors/1/message") != "order.status_invalid" || res.StrDef("", "errors/1/rule") != "option" {
+		t.Errorf("text:%v", text)
+		return
+	}
+	text, err = xhttp.PostText(strings.NewReader(`{"title":"title","status":"a","inline":{"name":"in","count":1}}`), "%v/json", ts.URL)
+	if err != nil || text != "title" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	//localize
+	verr := ValidStruct(&validItem{}, "#all", "")
+	var valids ValidationErrors
+	if !errors.As(verr, &valids) || len(valids) != 2 || !IsValidationError(verr) || !strings.Contains(verr.Error(), "name: data is empty") {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	valids.Localize(func(key string, args ...interface{}) string {
+		if key == "valid.required" {
+			return formatLocal("{field} is required", args...)
+		}
+		return key
+	})
+	if valids[0].Message != "name is required" {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	if verr = ValidStruct(&validItem{Name: "abc"}, "#all", "count"); verr != nil {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	//error
+	session := &Session{R: httptest.NewRequest("GET", "/?a=1", nil)}
+	if verr = session.ValidFormat(``); verr == nil || IsValidationError(verr) {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	if verr = session.ValidFormat(`a,R|S,L:0;b,R|S,L:0`, nil); verr == nil || IsValidationError(verr) {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	if verr = session.ValidFormat(`a,R|S`); verr == nil || IsValidationError(verr) {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	var a, b string
+	verr = session.ValidFormat(`a,R|S,L:2~8;b,R|I,R:0~10`, &a, &b)
+	origin := attrvalid.ValidAttrFormat(`a,R|S,L:2~8;b,R|I,R:0~10`, session, true, &a, &b)
+	if verr == nil || verr.Error() != origin.Error() || !errors.As(verr, &valids) || len(valids) != 2 {
+		t.Errorf("err:%v,origin:%v", verr, origin)
+		return
+	}
+	verr = session.ValidFormat(`b,R|S,L:1,b is required;a,R|S,L:2~8`, &b, &a)
+	if verr == nil || verr.Error() != "b is required" || !errors.As(verr, &valids) || len(valids) != 2 {
+		t.Errorf("err:%v", verr)
+		return
+	}
+	if verr = ValidStruct(&struct {
+		A string `json:"a" valid:"a,R"`
+		B string `json:"b" valid:"b,R|S,X:1"`
+	}{}, "#all", ""); fmt.Sprintf("%v", verr) != "a: valid error:a,R\nb: data is empty" {
+		t.Errorf("err:%v", verr)
+		return
+	}
+}
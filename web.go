@@ -3,6 +3,7 @@ package web
 import (
 	"compress/gzip"
 	"fmt"
+	"io"
 	"net/http"
 	"reflect"
 	"regexp"
@@ -104,10 +105,28 @@ func (f SessionEventFunc) OnTimeout(s Sessionable) {
 	f("TIMEOUT", s)
 }
 
+// OnDestroy is event handler on session destroy
+func (f SessionEventFunc) OnDestroy(s Sessionable) {
+	f("DESTROY", s)
+}
+
+// OnRegenerate is event handler on session id regenerate, the s is new session
+func (f SessionEventFunc) OnRegenerate(old string, s Sessionable) {
+	f("REGENERATE", s)
+}
+
+// OnEvict is event handler on session evict by capacity limit
+func (f SessionEventFunc) OnEvict(s Sessionable) {
+	f("EVICT", s)
+}
+
 // SessionEventHandler is interface to session event handler
 type SessionEventHandler interface {
 	OnCreate(s Sessionable)
 	OnTimeout(s Sessionable)
+	OnDestroy(s Sessionable)
+	OnRegenerate(old string, s Sessionable)
+	OnEvict(s Sessionable)
 }
 
 // SessionBuilder is interface to build the session
@@ -157,33 +176,68 @@ type Session struct {
 	W   http.ResponseWriter
 	R   *http.Request
 	Mux *SessionMux
-	// INT International
 	// V interface{} //response value.
+	vars    map[string]interface{}
+	closers []io.Closer
+	route   *regexp.Regexp
+	locale  string
+	allow   []string //the allowed method of matched path when method is not matched
 }
 
-// SetCookie will set cookie by key/value
-func (s *Session) SetCookie(key string, val string) {
-	cookie := &http.Cookie{}
-	cookie.Name = key
-	cookie.Domain = s.Mux.Domain
-	cookie.Path = s.Mux.Path
-	cookie.Value = val
-	cookie.MaxAge = 0
-	// if len(val) < 1 {
-	// 	cookie.Expires = time.Unix(0, 0*1e6)
-	// }
-	http.SetCookie(s.W, cookie)
+func (s *Session) addCloser(closer io.Closer) {
+	s.closers = append(s.closers, closer)
+}
+
+func (s *Session) close() {
+	for _, closer := range s.closers {
+		closer.Close()
+	}
+	s.closers = nil
+}
+
+// SetVar will set request scoped value by key, it will be released after request done
+func (s *Session) SetVar(key string, val interface{}) {
+	if s.vars == nil {
+		s.vars = map[string]interface{}{}
+	}
+	if val == nil {
+		delete(s.vars, key)
+	} else {
+		s.vars[key] = val
+	}
+}
+
+// Var will return request scoped value by key
+func (s *Session) Var(key string) (val interface{}) {
+	if s.vars != nil {
+		val = s.vars[key]
+	}
+	return
 }
 
-// Cookie will return cookie value by key
-func (s *Session) Cookie(key string) (val string) {
-	c, err := s.R.Cookie(key)
-	if err == nil && c != nil {
-		val = c.Value
+// PathParams will return the named group value of matched handler pattern
+func (s *Session) PathParams() (params map[string]string) {
+	params = map[string]string{}
+	if s.route == nil {
+		return
+	}
+	matched := s.route.FindStringSubmatch(s.R.URL.Path)
+	if matched == nil {
+		return
+	}
+	for i, name := range s.route.SubexpNames() {
+		if i > 0 && len(name) > 0 {
+			params[name] = matched[i]
+		}
 	}
 	return
 }
 
+// PathParam will return the named group value of matched handler pattern by name, like (?P<id>[0-9]+)
+func (s *Session) PathParam(name string) string {
+	return s.PathParams()[name]
+}
+
 // Redirect will send redirect to url
 func (s *Session) Redirect(url string) Result {
 	http.Redirect(s.W, s.R, url, http.StatusTemporaryRedirect)
@@ -215,9 +269,9 @@ func (s *Session) Get(key string) (val interface{}, err error) {
 	return
 }
 
-// ValidFormat is implement for attrvalid
+// ValidFormat is implement for attrvalid, all temple is checked and FormatValidationError is returned when some value is invalid
 func (s *Session) ValidFormat(format string, args ...interface{}) (err error) {
-	err = attrvalid.ValidAttrFormat(format, s, true, args...)
+	err = s.localizeValid(validFormat(s, format, args...))
 	return
 }
 
@@ -237,110 +291,11 @@ func (s *Session) Valid(target interface{}, filter string, args ...interface{})
 	return
 }
 
-// LocalValue will return local value.
-func (s *Session) LocalValue(key string) string {
-	return key
-}
-
-// func http_res(code int, data interface{}, msg string, dmsg string) util.Map {
-// 	res := make(util.Map)
-// 	res["code"] = code
-// 	if len(msg) > 0 {
-// 		res["msg"] = msg
-// 	}
-// 	if data != nil {
-// 		res["data"] = data
-// 	}
-// 	if len(dmsg) > 0 {
-// 		res["dmsg"] = dmsg
-// 	}
-// 	return res
-// }
-// func http_res_ext(code int, data interface{}, msg string, dmsg string, ext interface{}, pa interface{}) util.Map {
-// 	res := make(util.Map)
-// 	res["code"] = code
-// 	if len(msg) > 0 {
-// 		res["msg"] = msg
-// 	}
-// 	if data != nil {
-// 		res["data"] = data
-// 	}
-// 	if len(dmsg) > 0 {
-// 		res["dmsg"] = dmsg
-// 	}
-// 	if ext != nil {
-// 		res["ext"] = ext
-// 	}
-// 	if pa != nil {
-// 		res["pa"] = pa
-// 	}
-// 	return res
-// }
-
-// func json_res(code int, data interface{}, msg string, dmsg string) []byte {
-// 	res := http_res(code, data, msg, dmsg)
-// 	dbys, _ := json.Marshal(res)
-// 	return dbys
-// }
-
-// /* International */
-// func (s *Session) SetLocal(local string) {
-// 	if h.INT != nil {
-// 		h.INT.SetLocal(h, local)
-// 	}
-// }
-// func (s *Session) LocalVal(key string) string {
-// 	if h.INT != nil {
-// 		return h.INT.LocalVal(h, key)
-// 	} else {
-// 		return ""
-// 	}
-// }
-
 // Host will return request host
 func (s *Session) Host() string {
 	return s.R.Host
 }
 
-// /* --------------- Access-Language --------------- */
-// type LangQ struct {
-// 	Lang string
-// 	Q    float64
-// }
-// type LangQes []LangQ
-
-// func (l LangQes) Len() int           { return len(l) }
-// func (l LangQes) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-// func (l LangQes) Less(i, j int) bool { return l[i].Q > l[j].Q }
-
-// func (s *Session) AcceptLanguages() LangQes {
-// 	if len(h.R.Header["Accept-Language"]) < 1 {
-// 		return LangQes{}
-// 	}
-// 	lstr := h.R.Header["Accept-Language"][0]
-// 	var als LangQes = LangQes{} //all access languages.
-// 	regexp.MustCompile("[^;]*;q?[^,]*").ReplaceAllStringFunc(lstr, func(src string) string {
-// 		src = strings.Trim(src, "\t \n,")
-// 		lq := strings.Split(src, ";")
-// 		qua, err := strconv.ParseFloat(strings.Replace(lq[1], "q=", "", -1), 64)
-// 		if err != nil {
-// 			DebugLog("invalid Accept-Language q:%s", src)
-// 			return src
-// 		}
-// 		for _, lan := range strings.Split(lq[0], ",") {
-// 			als = append(als, LangQ{
-// 				Lang: lan,
-// 				Q:    qua,
-// 			})
-// 		}
-// 		return src
-// 	})
-// 	sort.Sort(als)
-// 	return als
-// }
-
-// /* --------------- Access-Language --------------- */
-
 // SessionMux session mux implement
 type SessionMux struct {
 	xmap.Valuable
@@ -348,6 +303,7 @@ type SessionMux struct {
 	Domain  string
 	Path    string
 	Builder SessionBuilder
+	Cookie  CookieOption //the cookie option of Session.SetCookie, see CookieOption.WithDefault
 	//
 	FilterEnable   bool
 	HandleEnable   bool
@@ -363,11 +319,14 @@ type SessionMux struct {
 	CompressLevel  int
 	compressRouter map[*regexp.Regexp]int
 	//
-	// INT           International
+	INT International //the international to localize message
 	//
 	ShowLog  bool
 	ShowSlow time.Duration
-	M        *monitor.Monitor
+	Debug    bool //debug mode, the debug message is sent by SendError when it is true
+	//
+	ErrorMapper ErrorMapper //the mapper to convert error returned by ErrorHandlerFunc or its panic to response
+	M           *monitor.Monitor
 }
 
 // NewSessionMux will return new SessionMux
@@ -394,7 +353,6 @@ func NewBuilderSessionMux(pre string, sb SessionBuilder) *SessionMux {
 	mux.FilterEnable = true
 	mux.HandleEnable = true
 	mux.ShowLog = false
-	// mux.INT = nil
 	mux.M = nil
 	mux.CompressLevel = gzip.BestSpeed
 	mux.compressRouter = map[*regexp.Regexp]int{}
@@ -501,6 +459,24 @@ func (s *SessionMux) checkMethod(reg *regexp.Regexp, m string) bool {
 	return ok && strings.Contains(tm, "*") || strings.Contains(tm, m)
 }
 
+// appendMethods will append the methods of handler method setting to allow list
+func appendMethods(allow []string, setting string) []string {
+	for _, method := range strings.Split(setting, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if len(method) < 1 || method == "*" || strings.HasPrefix(method, ":") {
+			continue
+		}
+		having := false
+		for _, m := range allow {
+			having = having || m == method
+		}
+		if !having {
+			allow = append(allow, method)
+		}
+	}
+	return allow
+}
+
 func (s *SessionMux) checkContinue(reg *regexp.Regexp) bool {
 	tm, ok := s.regexMethodM[reg]
 	return ok && strings.Contains(tm, ":"+Continue.String())
@@ -544,10 +520,12 @@ func (s *SessionMux) execHandler(hs *Session) (bool, Result) {
 		}
 		if !s.checkMethod(k, hs.R.Method) {
 			s.slog("not mathced method %v to %v", hs.R.Method, s.regexMethodM[k])
+			hs.allow = appendMethods(hs.allow, s.regexMethodM[k])
 			continue
 		}
 		var mid = ""
 		matched = true
+		hs.route = k
 		switch s.regexHandlerM[k] {
 		case 1:
 			fallthrough
@@ -603,6 +581,9 @@ func (s *SessionMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	beg := time.Now()
 	r.URL.Path = strings.TrimPrefix(r.URL.Path, s.Pre)
 	session := s.Builder.FindSession(w, r)
+	if wrapper, ok := session.(responseWrapper); ok {
+		w = wrapper.wrapResponse(w)
+	}
 	hs := &Session{
 		W:           w,
 		R:           r,
@@ -613,6 +594,12 @@ func (s *SessionMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.sessions[r] = hs
 	s.locker.Unlock()
 	defer func() {
+		if dirty, ok := hs.Sessionable.(DirtySession); hs.Sessionable != nil && (!ok || dirty.IsDirty()) {
+			if err := hs.Flush(); err != nil {
+				ErrorLog("SessionMux flush session %v fail with %v", hs.ID(), err)
+			}
+		}
+		hs.close()
 		s.locker.Lock()
 		delete(s.sessions, r) //remove the http session object.
 		s.locker.Unlock()
@@ -636,9 +623,13 @@ func (s *SessionMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var matched bool = false
 	//
 	defer func() {
-		if !matched { //if not matched
+		if !matched && len(hs.allow) > 0 { //if path matched but method not matched
+			s.slog("not matchd method %v on %s", r.Method, r.URL.Path)
+			w.Header().Set("Allow", strings.Join(hs.allow, ", "))
+			hs.SendStatusPage(http.StatusMethodNotAllowed)
+		} else if !matched { //if not matched
 			s.slog("not matchd any filter:%s", r.URL.Path)
-			http.NotFound(w, r)
+			hs.SendStatusPage(http.StatusNotFound)
 		}
 		// var tv interface{} = hs.V
 		// if s.FIND_V != nil {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,6 +19,53 @@ func init() {
 	xhttp.EnableCookie()
 }
 
+// serveCookie will serve GET request with Cookie header by handler, the header is not set when cookie is empty
+func serveCookie(handler http.Handler, path, cookie string) (w *httptest.ResponseRecorder) {
+	req := httptest.NewRequest("GET", path, nil)
+	if len(cookie) > 0 {
+		req.Header.Set("Cookie", cookie)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return
+}
+
+// responseCookie will return the last Set-Cookie of response by name, nil is returned when not found
+func responseCookie(w *httptest.ResponseRecorder, name string) (cookie *http.Cookie) {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			cookie = c
+		}
+	}
+	return
+}
+
+// findSession will find session of builder by request with Cookie header
+func findSession(builder SessionBuilder, cookie string) Sessionable {
+	req := httptest.NewRequest("GET", "/", nil)
+	if len(cookie) > 0 {
+		req.Header.Set("Cookie", cookie)
+	}
+	return builder.FindSession(httptest.NewRecorder(), req)
+}
+
+// testClock is the Clock which is moved by Forward
+type testClock struct {
+	now int64
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Now().UnixNano()}
+}
+
+func (c *testClock) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.now))
+}
+
+func (c *testClock) Forward(d time.Duration) {
+	atomic.AddInt64(&c.now, int64(d))
+}
+
 type AbcXML struct {
 	XMLName xml.Name `xml:"abc"`
 	A       string   `xml:"a" valid:"a,r|s,l:0;"`
@@ -252,6 +300,22 @@ func TestFilterHandler(t *testing.T) {
 			return
 		}
 	}
+	{ //vars
+		mux.FilterFunc("/vars/.*", func(s *Session) Result {
+			s.SetVar("a", "123")
+			s.SetVar("b", "456")
+			s.SetVar("b", nil)
+			return Continue
+		})
+		mux.HandleFunc("/vars/h1", func(s *Session) Result {
+			return s.Printf("%v%v", s.Var("a"), s.Var("b"))
+		})
+		text, err = xhttp.GetText("%v/vars/h1", ts.URL)
+		if err != nil || text != "123<nil>" {
+			t.Errorf("err:%v,text:%v", err, text)
+			return
+		}
+	}
 	{
 		mux.Print()
 		mux.State()
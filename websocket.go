@@ -0,0 +1,279 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// OriginChecker is interface to check if the websocket origin is allowed, filter.CORS is implemented it
+type OriginChecker interface {
+	CheckOrigin(origin string) bool
+}
+
+// WebSocketHandlerFunc is the func to handle websocket connection, the session is the upgrading request session
+type WebSocketHandlerFunc func(s *Session, conn *WebSocketConn)
+
+// WebSocketHandler is web.Handler to upgrade request to websocket
+type WebSocketHandler struct {
+	Handler        WebSocketHandlerFunc
+	Origin         OriginChecker //the origin checker, only same host is allowed when it is nil, request without Origin is always allowed
+	MaxMessageSize int           //the max message size in bytes, zero is websocket.DefaultMaxPayloadBytes
+	PingInterval   time.Duration //the interval to send ping frame, zero is disabled
+	ReadTimeout    time.Duration //the max idle time to wait next message, zero is disabled
+}
+
+// NewWebSocketHandler will return new websocket handler with default 30s ping interval
+func NewWebSocketHandler(h WebSocketHandlerFunc) *WebSocketHandler {
+	return &WebSocketHandler{
+		Handler:      h,
+		PingInterval: 30 * time.Second,
+	}
+}
+
+// HandleWebSocket will register websocket handler
+func (s *SessionMux) HandleWebSocket(pattern string, h WebSocketHandlerFunc) (handler *WebSocketHandler) {
+	handler = NewWebSocketHandler(h)
+	s.HandleMethod(pattern, handler, http.MethodGet)
+	return
+}
+
+func (w *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) < 1 {
+		return true
+	}
+	if w.Origin != nil {
+		return w.Origin.CheckOrigin(origin)
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// SrvHTTP is implement for web.Handler
+func (w *WebSocketHandler) SrvHTTP(hs *Session) Result {
+	if !strings.EqualFold(hs.R.Header.Get("Upgrade"), "websocket") {
+		http.Error(hs.W, "websocket upgrade is required", http.StatusBadRequest)
+		return Return
+	}
+	if !w.checkOrigin(hs.R) {
+		http.Error(hs.W, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return Return
+	}
+	hijacker := FindHijacker(hs.W)
+	if hijacker == nil {
+		http.Error(hs.W, fmt.Sprintf("response writer %T is not supported hijack", hs.W), http.StatusInternalServerError)
+		return Return
+	}
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) (err error) {
+			config.Origin, err = websocket.Origin(config, r)
+			if err == nil {
+				config.Header = w.handshakeHeader(hs)
+			}
+			return
+		},
+		Handler: func(raw *websocket.Conn) {
+			raw.MaxPayloadBytes = w.MaxMessageSize
+			conn := NewWebSocketConn(raw)
+			conn.ReadTimeout = w.ReadTimeout
+			hs.addCloser(conn)
+			if w.PingInterval > 0 {
+				go conn.loopPing(w.PingInterval)
+			}
+			w.Handler(hs, conn)
+			conn.Close()
+		},
+	}
+	server.ServeHTTP(&hijackWriter{ResponseWriter: hs.W, Hijacker: hijacker}, hs.R)
+	return Return
+}
+
+// handshakeHeader will return the response header which is set before upgrading to send it in handshake,
+// the session cookie is written to header before it is returned
+func (w *WebSocketHandler) handshakeHeader(hs *Session) (header http.Header) {
+	if sender, ok := hs.Sessionable.(headerSender); ok {
+		if err := sender.sendHeader(); err != nil {
+			WarnLog("WebSocketHandler write session header fail with %v", err)
+		}
+	}
+	header = hs.W.Header().Clone()
+	return
+}
+
+// WebSocketConn is websocket connection which support read/write text, binary and json message
+type WebSocketConn struct {
+	*websocket.Conn
+	ReadTimeout time.Duration //the max idle time to wait next message, zero is disabled
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewWebSocketConn will return new websocket connection by raw connection
+func NewWebSocketConn(raw *websocket.Conn) (conn *WebSocketConn) {
+	conn = &WebSocketConn{
+		Conn: raw,
+		done: make(chan struct{}),
+	}
+	return
+}
+
+type webSocketFrame struct {
+	Data   []byte
+	Binary bool
+}
+
+var webSocketCodec = websocket.Codec{
+	Marshal: func(v interface{}) (data []byte, payloadType byte, err error) {
+		frame := v.(*webSocketFrame)
+		data, payloadType = frame.Data, websocket.TextFrame
+		if frame.Binary {
+			payloadType = websocket.BinaryFrame
+		}
+		return
+	},
+}
+
+var webSocketPingCodec = websocket.Codec{
+	Marshal: func(v interface{}) (data []byte, payloadType byte, err error) {
+		data, payloadType = v.([]byte), websocket.PingFrame
+		return
+	},
+}
+
+func (c *WebSocketConn) loopPing(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.Ping(nil); err != nil {
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// ReadMessage will read one message, binary is true if it is binary message,
+// the read deadline is extended by ReadTimeout when message or pong frame is received
+func (c *WebSocketConn) ReadMessage() (data []byte, binary bool, err error) {
+	c.extendDeadline()
+	for {
+		frame, xerr := c.Conn.NewFrameReader()
+		if xerr != nil {
+			err = xerr
+			return
+		}
+		if frame.PayloadType() == websocket.PongFrame {
+			c.extendDeadline()
+		}
+		if frame, err = c.Conn.HandleFrame(frame); err != nil {
+			return
+		}
+		if frame == nil { //control frame
+			continue
+		}
+		max := c.MaxPayloadBytes
+		if max < 1 {
+			max = websocket.DefaultMaxPayloadBytes
+		}
+		if frame.Len() > max {
+			io.Copy(io.Discard, frame)
+			err = websocket.ErrFrameTooLarge
+			return
+		}
+		binary = frame.PayloadType() == websocket.BinaryFrame
+		data, err = io.ReadAll(frame)
+		return
+	}
+}
+
+func (c *WebSocketConn) extendDeadline() {
+	if c.ReadTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	}
+}
+
+// ReadText will read one message as text
+func (c *WebSocketConn) ReadText() (text string, err error) {
+	data, _, err := c.ReadMessage()
+	text = string(data)
+	return
+}
+
+// ReadJSON will read one message and unmarshal it to v
+func (c *WebSocketConn) ReadJSON(v interface{}) (err error) {
+	data, _, err := c.ReadMessage()
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	return
+}
+
+// WriteMessage will write one message, it is safe for concurrent use
+func (c *WebSocketConn) WriteMessage(data []byte, binary bool) (err error) {
+	err = webSocketCodec.Send(c.Conn, &webSocketFrame{Data: data, Binary: binary})
+	return
+}
+
+// WriteText will write one text message
+func (c *WebSocketConn) WriteText(text string) (err error) {
+	err = c.WriteMessage([]byte(text), false)
+	return
+}
+
+// WriteBinary will write one binary message
+func (c *WebSocketConn) WriteBinary(data []byte) (err error) {
+	err = c.WriteMessage(data, true)
+	return
+}
+
+// WriteJSON will marshal v to json and write it as text message
+func (c *WebSocketConn) WriteJSON(v interface{}) (err error) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = c.WriteMessage(data, false)
+	}
+	return
+}
+
+// Ping will send ping frame, the pong frame is handled automatic
+func (c *WebSocketConn) Ping(data []byte) (err error) {
+	err = webSocketPingCodec.Send(c.Conn, data)
+	return
+}
+
+// Done will return the channel which is closed when connection closed
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Close will close the connection, it is called automatic after handler returned
+func (c *WebSocketConn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.Conn.Close()
+	})
+	return
+}
+
+type hijackWriter struct {
+	http.ResponseWriter
+	http.Hijacker
+}
+
+// FindHijacker will return http.Hijacker from response writer or its wrapped writer, return nil if not found
+func FindHijacker(w http.ResponseWriter) (hijacker http.Hijacker) {
+	hijacker, _ = findResponse[http.Hijacker](w)
+	return
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codingeasygo/util/xhttp"
+	"github.com/codingeasygo/util/xmap"
+	"golang.org/x/net/websocket"
+)
+
+type testOriginChecker map[string]bool
+
+func (t testOriginChecker) CheckOrigin(origin string) bool {
+	return t[origin]
+}
+
+func TestWebSocket(t *testing.T) {
+	mux := NewSessionMux("")
+	mux.FilterFunc("^.*$", func(s *Session) Result {
+		s.W = &unwrapWriter{ResponseWriter: s.W}
+		s.SetVar("user", "u1")
+		return Continue
+	})
+	errAll := make(chan error, 1)
+	echo := mux.HandleWebSocket("^/echo$", func(s *Session, conn *WebSocketConn) {
+		conn.WriteText(s.Var("user").(string))
+		for {
+			data, binary, err := conn.ReadMessage()
+			if err != nil {
+				errAll <- err
+				break
+			}
+			if binary {
+				conn.WriteBinary(data)
+				continue
+			}
+			msg := xmap.M{}
+			if err = conn.ReadJSON(&msg); err != nil {
+				errAll <- err
+				break
+			}
+			conn.WriteJSON(xmap.M{"text": string(data), "json": msg})
+		}
+	})
+	echo.MaxMessageSize = 16
+	echo.PingInterval = 10 * time.Millisecond
+	echo.ReadTimeout = time.Second
+	echo.Origin = testOriginChecker{"http://allowed.com": true}
+	ts := httptest.NewServer(mux)
+	wsURL := strings.Replace(ts.URL, "http://", "ws://", 1) + "/echo"
+	conn, err := websocket.Dial(wsURL, "", "http://allowed.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var text string
+	if err = websocket.Message.Receive(conn, &text); err != nil || text != "u1" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	//binary
+	var data []byte
+	websocket.Message.Send(conn, []byte("bin"))
+	if err = websocket.Message.Receive(conn, &data); err != nil || string(data) != "bin" {
+		t.Errorf("err:%v,data:%v", err, data)
+		return
+	}
+	//text and json
+	time.Sleep(30 * time.Millisecond) //wait ping
+	websocket.Message.Send(conn, "abc")
+	websocket.Message.Send(conn, `{"a":1}`)
+	res := xmap.M{}
+	if err = websocket.JSON.Receive(conn, &res); err != nil || res.Str("text") != "abc" || res.Int64("json/a") != 1 {
+		t.Errorf("err:%v,res:%v", err, res)
+		return
+	}
+	//too large
+	websocket.Message.Send(conn, strings.Repeat("x", 100))
+	if err = <-errAll; err != websocket.ErrFrameTooLarge {
+		t.Error(err)
+		return
+	}
+	conn.Close()
+	//origin
+	_, err = websocket.Dial(wsURL, "", "http://denied.com")
+	if err == nil {
+		t.Error(err)
+		return
+	}
+	//not upgrade
+	_, res2, err := xhttp.GetHeaderText(nil, "%v/echo", ts.URL)
+	if err != nil || res2.StatusCode != http.StatusBadRequest {
+		t.Errorf("err:%v,res:%v", err, res2)
+		return
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	mux := NewSessionMux("")
+	handler := mux.HandleWebSocket("^/ws$", func(s *Session, conn *WebSocketConn) {
+		conn.WriteText("ok")
+	})
+	handler.PingInterval = 0
+	ts := httptest.NewServer(mux)
+	wsURL := strings.Replace(ts.URL, "http://", "ws://", 1) + "/ws"
+	//same host
+	conn, err := websocket.Dial(wsURL, "", ts.URL)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var text string
+	if err = websocket.Message.Receive(conn, &text); err != nil || text != "ok" {
+		t.Errorf("err:%v,text:%v", err, text)
+		return
+	}
+	conn.Close()
+	//other host
+	if _, err = websocket.Dial(wsURL, "", "http://other.com"); err == nil {
+		t.Error(err)
+		return
+	}
+	//no origin
+	if !handler.checkOrigin(httptest.NewRequest("GET", "/ws", nil)) {
+		t.Error("error")
+		return
+	}
+	//not hijack
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	handler.SrvHTTP(&Session{W: recorder, R: req})
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("code:%v", recorder.Code)
+		return
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	cookieBuilder, _ := NewCookieSessionBuilder("", "/", "wtest", time.Minute, []byte("key"))
+	for _, builder := range []SessionBuilder{NewMemSessionBuilder("", "/", "wtest", time.Minute), cookieBuilder} {
+		mux := NewBuilderSessionMux("", builder)
+		mux.FilterFunc("^.*$", func(s *Session) Result {
+			s.SetValue("a", 1)
+			return Continue
+		})
+		errAll := make(chan error, 1)
+		handler := mux.HandleWebSocket("^/ws$", func(s *Session, conn *WebSocketConn) {
+			text, err := conn.ReadText()
+			if err == nil && text != "abc" {
+				err = fmt.Errorf("text:%v", text)
+			}
+			errAll <- err
+		})
+		handler.PingInterval = 10 * time.Millisecond
+		handler.ReadTimeout = 50 * time.Millisecond
+		ts := httptest.NewServer(mux)
+		//cookie in handshake
+		raw, err := net.Dial("tcp", ts.Listener.Addr().String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		fmt.Fprintf(raw, "GET /ws HTTP/1.1\r\nHost: %v\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", ts.Listener.Addr())
+		res, err := http.ReadResponse(bufio.NewReader(raw), nil)
+		if err != nil || res.StatusCode != http.StatusSwitchingProtocols || len(res.Cookies()) != 1 || res.Cookies()[0].Name != "wtest" {
+			t.Errorf("err:%v,res:%v", err, res)
+			return
+		}
+		raw.Close()
+		<-errAll
+		//pong extend deadline
+		conn, err := websocket.Dial(strings.Replace(ts.URL, "http://", "ws://", 1)+"/ws", "", ts.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		go func() {
+			var text string
+			websocket.Message.Receive(conn, &text) //reply pong
+		}()
+		time.Sleep(200 * time.Millisecond)
+		websocket.Message.Send(conn, "abc")
+		if err = <-errAll; err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+		ts.Close()
+	}
+}